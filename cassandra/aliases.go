@@ -0,0 +1,202 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// aliases are the groups of ObjectName values naming the same MBeans in
+// different versions of Cassandra, by the key they're the values of. The
+// tables are reported under ColumnFamily before 3.0, and under both
+// ColumnFamily and Table from 3.0 on.
+var aliases = map[string][][]string{
+	"type": {{"Table", "ColumnFamily"}},
+}
+
+// movedPools are the paths of the thread pools listed under several paths
+// across the embedded catalogs, by pool name. The request, internal and
+// transport paths are categories of pools which exist side by side, so a
+// path is only aliased for the pools which moved between them. They're
+// read from the catalogs the first time a path is aliased.
+var movedPools struct {
+	once  sync.Once
+	paths map[string][]string
+}
+
+// aliasesOf returns the other values of the alias group of the key's value.
+// The elements following the value name the pool of a thread pool path.
+func aliasesOf(key, value string, rest []string) []string {
+	if key == "path" {
+		if len(rest) < 2 || rest[0] != "scope" {
+			return nil
+		}
+		return otherAliases(poolPaths(rest[1]), value)
+	}
+	for _, group := range aliases[key] {
+		if others := otherAliases(group, value); others != nil {
+			return others
+		}
+	}
+	return nil
+}
+
+// otherAliases returns the other values of the group if it holds the value
+func otherAliases(group []string, value string) []string {
+	for i, alias := range group {
+		if alias == value {
+			return append(append([]string{}, group[:i]...), group[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// poolPaths returns the paths the thread pool moved between, if any
+func poolPaths(pool string) []string {
+	movedPools.once.Do(func() {
+		trees := []*node{}
+		read := map[string]bool{}
+		for _, c := range catalogs {
+			if read[c.tree] {
+				continue
+			}
+			read[c.tree] = true
+			if tree, err := readMetricAPI(c.tree); err == nil {
+				trees = append(trees, tree)
+			}
+		}
+		movedPools.paths = threadPoolMoves(trees)
+	})
+	return movedPools.paths[pool]
+}
+
+// threadPoolMoves returns the paths of the thread pools which the trees
+// list under more than one path, by pool name
+func threadPoolMoves(trees []*node) map[string][]string {
+	found := map[string]map[string]bool{}
+	for _, tree := range trees {
+		paths := tree.descendant(MetricDomain, "type", "ThreadPools", "path")
+		if paths == nil {
+			continue
+		}
+		for path, child := range paths.Children {
+			pools := child.descendant("scope")
+			if pools == nil {
+				continue
+			}
+			for pool := range pools.Children {
+				if found[pool] == nil {
+					found[pool] = map[string]bool{}
+				}
+				found[pool][path] = true
+			}
+		}
+	}
+
+	moves := map[string][]string{}
+	for pool, paths := range found {
+		if len(paths) < 2 {
+			continue
+		}
+		for path := range paths {
+			moves[pool] = append(moves[pool], path)
+		}
+		sort.Strings(moves[pool])
+	}
+	return moves
+}
+
+// descendant returns the node reached by the names below the node, if any
+func (n *node) descendant(names ...string) *node {
+	for _, name := range names {
+		if n = n.Children[name]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// child returns the child of the name searched for the rest of the path.
+// If the path doesn't reach a target through the child of the name, it's
+// resolved through the aliases of the name under the node's key, so a path
+// names the MBean whichever version of Cassandra the node runs. The alias
+// the child was found by is returned along with it.
+func (n *node) child(c *cycle, name string, names []string, index int) (*node, string) {
+	child, ok := n.Children[name]
+	if ok && child.reaches(c, names, index+1) {
+		return child, name
+	}
+	for _, alias := range aliasesOf(n.Name, name, names[index+1:]) {
+		if other, found := n.Children[alias]; found && (!ok || other.reaches(c, names, index+1)) {
+			return other, alias
+		}
+	}
+	return child, name
+}
+
+// reaches returns true if the path reaches a target or a data point
+// below the node. The attributes of the targets are not read.
+func (n *node) reaches(c *cycle, names []string, index int) bool {
+	if n.Target != nil || index == len(names) {
+		return n.Target != nil || n.Data != nil
+	}
+	for _, token := range strings.Split(names[index], Pipe) {
+		if token == Wildcard {
+			for _, child := range n.Children {
+				if child.reaches(c, names, index+1) {
+					return true
+				}
+			}
+		} else if child, ok := n.Children[token]; ok && child.reaches(c, names, index+1) {
+			return true
+		}
+	}
+	for _, child := range n.tagChildren(c, names[index]) {
+		if child.reaches(c, names, index) {
+			return true
+		}
+	}
+	return false
+}
+
+// withAlias records in the results that the value of the key was requested
+// as name, so they're reported under the requested name
+func withAlias(results []nodeData, key, name string) {
+	for i := range results {
+		aliased := map[string]string{key: name}
+		for k, v := range results[i].Aliases {
+			aliased[k] = v
+		}
+		results[i].Aliases = aliased
+	}
+}
+
+// mbeanElements returns the namespace elements of the MBean of the data
+// point, with the values it was requested by under another alias replaced
+func (d nodeData) mbeanElements() []string {
+	ns := makeLitteralNamespace(d.MBean, "")
+	for i := 1; i+1 < len(ns); i += 2 {
+		if name, ok := d.Aliases[ns[i]]; ok {
+			ns[i+1] = name
+		}
+	}
+	return ns
+}
@@ -0,0 +1,126 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAliases(t *testing.T) {
+	Convey("Given a node exposing the MBeans of another version", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans["org.apache.cassandra.metrics:type=ColumnFamily,keyspace=system,scope=peers,name=ReadLatency"] = []Attribute{
+			{Name: "Count", Type: "long", Value: int64(7)},
+		}
+		transport.mbeans["org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks"] = []Attribute{
+			{Name: "Value", Type: "int", Value: int64(1)},
+		}
+		transport.mbeans["org.apache.cassandra.metrics:type=ThreadPools,path=internal,scope=CompactionExecutor,name=ActiveTasks"] = []Attribute{
+			{Name: "Value", Type: "int", Value: int64(2)},
+		}
+		cc := newFakeClient(transport)
+
+		collect := func(elems ...string) []plugin.MetricType {
+			ns := append([]string{"intel", "cassandra", "node", "*", "org_apache_cassandra_metrics"}, elems...)
+			metrics, errs := cc.collectMetrics([]plugin.MetricType{{Namespace_: core.NewNamespace(ns...)}})
+			So(errs, ShouldBeEmpty)
+			return metrics
+		}
+
+		Convey("a table requested under Table should be read from ColumnFamily and reported as requested", func() {
+			metrics := collect("type", "Table", "keyspace", "*", "scope", "*", "name", "ReadLatency", "Count")
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/intel/cassandra/node/node1/org.apache.cassandra.metrics/type/Table/keyspace/system/scope/peers/name/ReadLatency/Count")
+			So(metrics[0].Data(), ShouldEqual, int64(7))
+		})
+
+		Convey("a thread pool should be found on the path it moved to", func() {
+			poolPaths("")
+			paths := movedPools.paths
+			movedPools.paths = map[string][]string{"CompactionExecutor": {"internal", "request"}}
+			defer func() { movedPools.paths = paths }()
+
+			metrics := collect("type", "ThreadPools", "path", "request", "scope", "CompactionExecutor", "name", "ActiveTasks", "Value")
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace().Strings()[8], ShouldEqual, "request")
+			So(metrics[0].Data(), ShouldEqual, int64(2))
+		})
+
+		Convey("a thread pool which didn't move should not be found on another path", func() {
+			ns := core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics", "type", "ThreadPools", "path", "request", "scope", "CompactionExecutor", "name", "ActiveTasks", "Value")
+			metrics, _ := cc.collectMetrics([]plugin.MetricType{{Namespace_: ns}})
+			So(metrics, ShouldBeEmpty)
+		})
+
+		Convey("the MBean of the requested name should be read if the node exposes it", func() {
+			transport.mbeans["org.apache.cassandra.metrics:type=Table,keyspace=system,scope=peers,name=ReadLatency"] = []Attribute{
+				{Name: "Count", Type: "long", Value: int64(9)},
+			}
+			cc = newFakeClient(transport)
+			metrics := collect("type", "Table", "keyspace", "system", "scope", "peers", "name", "ReadLatency", "Count")
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Data(), ShouldEqual, int64(9))
+		})
+
+		Convey("a wildcard should not be aliased", func() {
+			So(collect("type", "*", "keyspace", "*", "scope", "*", "name", "ReadLatency", "Count"), ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestThreadPoolMoves(t *testing.T) {
+	Convey("Given the catalogs of different versions", t, func() {
+		tree := func(mbeans ...string) *node {
+			return newTree(mbeans)
+		}
+		older := tree(
+			"org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks",
+			"org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=Native-Transport-Requests,name=ActiveTasks",
+			"org.apache.cassandra.metrics:type=ThreadPools,path=internal,scope=CompactionExecutor,name=ActiveTasks",
+		)
+		newer := tree(
+			"org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks",
+			"org.apache.cassandra.metrics:type=ThreadPools,path=transport,scope=Native-Transport-Requests,name=ActiveTasks",
+			"org.apache.cassandra.metrics:type=ThreadPools,path=internal,scope=CompactionExecutor,name=ActiveTasks",
+		)
+
+		Convey("only the pools listed under several paths should be aliased", func() {
+			moves := threadPoolMoves([]*node{older, newer})
+			So(moves, ShouldResemble, map[string][]string{"Native-Transport-Requests": {"request", "transport"}})
+		})
+
+		Convey("the path of a moved pool should be aliased by the pool it names", func() {
+			poolPaths("")
+			paths := movedPools.paths
+			movedPools.paths = threadPoolMoves([]*node{older, newer})
+			defer func() { movedPools.paths = paths }()
+
+			So(aliasesOf("path", "request", []string{"scope", "Native-Transport-Requests", "name"}), ShouldResemble, []string{"transport"})
+			So(aliasesOf("path", "request", []string{"scope", "ReadStage", "name"}), ShouldBeEmpty)
+			So(aliasesOf("type", "Table", []string{"keyspace"}), ShouldResemble, []string{"ColumnFamily"})
+		})
+	})
+}
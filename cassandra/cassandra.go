@@ -19,14 +19,12 @@ limitations under the License.
 package cassandra
 
 import (
-	"reflect"
-	"strings"
 	"time"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
-	"github.com/intelsdi-x/snap/core"
-	"github.com/intelsdi-x/snap/core/cdata"
 )
 
 // const defines constant varaibles
@@ -40,12 +38,83 @@ const (
 
 	// Timeout duration
 	DefaultTimeout = 5 * time.Second
-
-	CassURL    = "url"
-	Port       = "port"
-	Hostname   = "hostname"
-	InvalidURL = "Invalid URL in Global configuration"
-	NoHostname = "No hostname define in Global configuration"
+	// DefaultPort the default port of the MX4J adaptor
+	DefaultPort = 8082
+	// DefaultJolokiaPort the default port of the Jolokia agent
+	DefaultJolokiaPort = 8778
+	// DefaultConcurrency the default number of MBeans of a node read at the same time
+	DefaultConcurrency = 8
+
+	CassURL       = "url"
+	Port          = "port"
+	Hostname      = "hostname"
+	CassTransport = "transport"
+	CacheTTL      = "cache_ttl"
+	CacheMBeans   = "cache_mbeans"
+	InvalidURL    = "Invalid URL in Global configuration"
+	NoHostname    = "No hostname define in Global configuration"
+
+	// MX4JTransport reads the MBeans through the MX4J HTTP adaptor
+	MX4JTransport = "mx4j"
+	// JolokiaTransport reads the MBeans through the Jolokia agent
+	JolokiaTransport = "jolokia"
+	InvalidTransport = "Invalid transport in Global configuration"
+	InvalidDuration  = "Invalid duration in Global configuration"
+	NodeDownErr      = "Collection of the node's metrics failed"
+	NodeSkippedErr   = "The node is down, its metrics are left out of the collection"
+
+	NamespaceFailedErr = "Collection of the namespace failed"
+
+	// RequestTimeout the timeout of each request to a node
+	RequestTimeout = "timeout"
+	// Scheme the scheme of the MX4J or Jolokia endpoint, http or https
+	Scheme = "scheme"
+	// CAFile the PEM bundle of the CAs verifying the node's certificate
+	CAFile = "ca_file"
+	// CertFile the PEM client certificate for mutual TLS
+	CertFile = "cert_file"
+	// KeyFile the PEM private key of the client certificate
+	KeyFile = "key_file"
+	// ServerName overrides the name the node's certificate is verified against
+	ServerName = "server_name"
+	// InsecureSkipVerify disables the verification of the node's certificate
+	InsecureSkipVerify = "insecure_skip_verify"
+
+	// Username the user of basic authentication
+	Username = "username"
+	// Password the password of basic authentication
+	Password = "password"
+	// PasswordFile the file the password is read from
+	PasswordFile = "password_file"
+	// PasswordEnv the environment variable the password is read from
+	PasswordEnv = "password_env"
+	// Token the token of bearer authentication
+	Token = "token"
+	// TokenFile the file the token is read from
+	TokenFile = "token_file"
+	// TokenEnv the environment variable the token is read from
+	TokenEnv = "token_env"
+	// CollectTimeout the time a node is given to collect its metrics
+	CollectTimeout = "collect_timeout"
+	// Discovery enables finding the nodes of the ring through gossip
+	Discovery = "discovery"
+	// DiscoveryInterval the interval the nodes of the ring are discovered at
+	DiscoveryInterval = "discovery_interval"
+	// PartialResults returns the metrics which could be read along with
+	// the failed namespaces instead of failing the collection
+	PartialResults = "partial_results"
+	// StateMetrics adds a numeric state metric for every enum and boolean attribute
+	StateMetrics = "state_metrics"
+	// TagMode reports the keyspaces and tables as tags rather than namespace elements
+	TagMode = "tag_mode"
+	// NamespaceEncoding the encoding of the namespace elements, escaped or legacy
+	NamespaceEncoding = "namespace_encoding"
+	// Concurrency the maximum number of MBeans of a node read at the same time
+	Concurrency = "concurrency"
+	// RefreshInterval the interval the searchable tree is merged with the node's MBeans at
+	RefreshInterval = "refresh_interval"
+	// CatalogDir the directory the catalogs discovered on the nodes are saved in and read from
+	CatalogDir = "catalog_dir"
 )
 
 // Meta returns the snap plug.PluginMeta type
@@ -55,75 +124,123 @@ func Meta() *plugin.PluginMeta {
 
 // NewCassandraCollector returns a new instance of Cassandra struct
 func NewCassandraCollector() *Cassandra {
-	return &Cassandra{}
+	return &Cassandra{clusters: newRegistry(DefaultIdleTimeout)}
 }
 
 // Cassandra struct
 type Cassandra struct {
-	client *CassClient
+	clusters *registry
 }
 
-// CollectMetrics collects metrics from Cassandra through JMX
+// CollectMetrics collects metrics from Cassandra through JMX. The metrics are
+// collected from the cluster of their config, so tasks collecting different
+// clusters don't interfere. The nodes are collected concurrently. If a requested
+// namespace can't be read the collection fails with CollectErrors, unless the
+// config asks for partial results. Then the metrics which could be read are
+// returned along with an error metric for every failed namespace. A node which
+// is down is left out of the collection of the others and logged, unless every
+// node of the cluster is down.
 func (p *Cassandra) CollectMetrics(mts []plugin.MetricType) ([]plugin.MetricType, error) {
-	metrics := []plugin.MetricType{}
+	groups := map[string][]plugin.MetricType{}
+	for _, m := range mts {
+		key := configKey(m.Config())
+		groups[key] = append(groups[key], m)
+	}
 
-	if p.client == nil {
-		err := p.loadMetricAPI(mts[0].Config())
+	metrics := []plugin.MetricType{}
+	for _, group := range groups {
+		cl, err := p.clusters.get(group[0].Config())
 		if err != nil {
 			return nil, err
 		}
-	}
-
-	for _, m := range mts {
-		results := []nodeData{}
-		search := strings.Split(replaceUnderscoreToDot(strings.TrimLeft(m.Namespace().String(), "/")), "/")
-		if len(search) > 3 {
-			p.client.Root.Get(p.client.client.GetUrl(), search[4:], 0, &results)
+		mets, errs := cl.collectMetrics(group)
+		metrics = append(metrics, mets...)
+		if len(errs) == 0 {
+			continue
 		}
-
-		for _, result := range results {
-			ns := append([]string{"intel", "cassandra", "node", p.client.host}, strings.Split(result.Path, Slash)...)
-			metrics = append(metrics, plugin.MetricType{
-				Namespace_: core.NewNamespace(ns...),
-				Timestamp_: time.Now(),
-				Data_:      result.Data,
-				Unit_:      reflect.TypeOf(result.Data).String(),
-			})
+		if !cl.partial {
+			var down []string
+			errs, down = cl.withoutDownNodes(errs)
+			for _, node := range down {
+				cassLog.WithFields(log.Fields{
+					"_block": "CollectMetrics",
+					"node":   node,
+				}).Warn(NodeSkippedErr)
+			}
+			if len(errs) > 0 {
+				return nil, errs
+			}
+			continue
+		}
+		for _, e := range errs {
+			cassLog.WithFields(log.Fields{
+				"_block": "CollectMetrics",
+				"error":  e,
+			}).Warn(NamespaceFailedErr)
+			metrics = append(metrics, errorMetric(e, cl.encoding))
 		}
 	}
-
 	return metrics, nil
 }
 
 // GetMetricTypes returns the metric types exposed by Cassandra
 func (p *Cassandra) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
-	return NewEmptyCassClient().getMetricType(cfg)
+	mts, err := NewEmptyCassClient().getMetricType(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(mts, errorMetricType()), nil
 }
 
-// GetConfigPolicy returns a ConfigPolicy
+// GetConfigPolicy returns a ConfigPolicy declaring every config item
+// the collector supports along with its default value
 func (p *Cassandra) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
 	c := cpolicy.New()
-	return c, nil
-}
-
-// loadMetricAPI returns the root node
-func (p *Cassandra) loadMetricAPI(config *cdata.ConfigDataNode) error {
-	var err error
-	// inits CassClient
-	p.client, err = initClient(plugin.ConfigType{ConfigDataNode: config})
-	if err != nil {
-		return err
-	}
 
-	// reads the root metric node from the memory
-	nod, err := readMetricAPI()
-	if err != nil {
-		err = p.client.buidMetricAPI()
-		if err != nil {
-			return err
-		}
-	} else {
-		p.client.Root = nod
-	}
-	return nil
+	url, _ := cpolicy.NewStringRule(CassURL, true)
+	// the default port depends on the transport
+	port, _ := cpolicy.NewIntegerRule(Port, false)
+	port.SetMinimum(1)
+	port.SetMaximum(65535)
+	transport, _ := cpolicy.NewStringRule(CassTransport, false, MX4JTransport)
+	// the durations are numbers of seconds, so the policy rejects malformed ones
+	timeout, _ := cpolicy.NewIntegerRule(RequestTimeout, false, int(DefaultTimeout/time.Second))
+	timeout.SetMinimum(1)
+	scheme, _ := cpolicy.NewStringRule(Scheme, false, HTTPScheme)
+	caFile, _ := cpolicy.NewStringRule(CAFile, false)
+	certFile, _ := cpolicy.NewStringRule(CertFile, false)
+	keyFile, _ := cpolicy.NewStringRule(KeyFile, false)
+	serverName, _ := cpolicy.NewStringRule(ServerName, false)
+	insecureSkipVerify, _ := cpolicy.NewBoolRule(InsecureSkipVerify, false, false)
+	username, _ := cpolicy.NewStringRule(Username, false)
+	password, _ := cpolicy.NewStringRule(Password, false)
+	passwordFile, _ := cpolicy.NewStringRule(PasswordFile, false)
+	passwordEnv, _ := cpolicy.NewStringRule(PasswordEnv, false)
+	token, _ := cpolicy.NewStringRule(Token, false)
+	tokenFile, _ := cpolicy.NewStringRule(TokenFile, false)
+	tokenEnv, _ := cpolicy.NewStringRule(TokenEnv, false)
+	collectTimeout, _ := cpolicy.NewIntegerRule(CollectTimeout, false, 0)
+	collectTimeout.SetMinimum(0)
+	cacheTTL, _ := cpolicy.NewIntegerRule(CacheTTL, false, 0)
+	cacheTTL.SetMinimum(0)
+	cacheMBeans, _ := cpolicy.NewStringRule(CacheMBeans, false, ".*")
+	discovery, _ := cpolicy.NewBoolRule(Discovery, false, false)
+	discoveryInterval, _ := cpolicy.NewIntegerRule(DiscoveryInterval, false, int(DefaultDiscoveryInterval/time.Second))
+	discoveryInterval.SetMinimum(0)
+	partialResults, _ := cpolicy.NewBoolRule(PartialResults, false, false)
+	stateMetrics, _ := cpolicy.NewBoolRule(StateMetrics, false, false)
+	tagMode, _ := cpolicy.NewBoolRule(TagMode, false, false)
+	namespaceEncoding, _ := cpolicy.NewStringRule(NamespaceEncoding, false, LegacyEncoding)
+	concurrency, _ := cpolicy.NewIntegerRule(Concurrency, false, DefaultConcurrency)
+	concurrency.SetMinimum(1)
+	refreshInterval, _ := cpolicy.NewIntegerRule(RefreshInterval, false, int(DefaultRefreshInterval/time.Second))
+	refreshInterval.SetMinimum(0)
+	catalogDir, _ := cpolicy.NewStringRule(CatalogDir, false)
+
+	node := cpolicy.NewPolicyNode()
+	node.Add(url, port, transport, timeout, scheme, caFile, certFile, keyFile, serverName, insecureSkipVerify)
+	node.Add(username, password, passwordFile, passwordEnv, token, tokenFile, tokenEnv)
+	node.Add(collectTimeout, cacheTTL, cacheMBeans, discovery, discoveryInterval, partialResults, stateMetrics, tagMode, namespaceEncoding, concurrency, refreshInterval, catalogDir)
+	c.Add([]string{"intel", "cassandra"}, node)
+	return c, nil
 }
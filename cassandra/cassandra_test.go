@@ -22,10 +22,17 @@ limitations under the License.
 package cassandra
 
 import (
+	"net"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -53,6 +60,190 @@ func TestESPlugin(t *testing.T) {
 			Convey("So config policy should be a cpolicy.ConfigPolicy", func() {
 				So(configPolicy, ShouldHaveSameTypeAs, &cpolicy.ConfigPolicy{})
 			})
+			Convey("So config policy should fill in the defaults", func() {
+				cfg, errs := configPolicy.Get([]string{"intel", "cassandra"}).Process(map[string]ctypes.ConfigValue{
+					CassURL: ctypes.ConfigValueStr{Value: "127.0.0.1"},
+				})
+				So(errs.HasErrors(), ShouldBeFalse)
+				So((*cfg)[Port], ShouldBeNil)
+				So((*cfg)[CassTransport], ShouldResemble, ctypes.ConfigValueStr{Value: MX4JTransport})
+			})
+			Convey("So config policy should reject malformed and negative durations", func() {
+				for _, key := range []string{RequestTimeout, CacheTTL, CollectTimeout, DiscoveryInterval, RefreshInterval} {
+					for _, value := range []ctypes.ConfigValue{ctypes.ConfigValueStr{Value: "5m"}, ctypes.ConfigValueInt{Value: -1}} {
+						_, errs := configPolicy.Get([]string{"intel", "cassandra"}).Process(map[string]ctypes.ConfigValue{
+							CassURL: ctypes.ConfigValueStr{Value: "127.0.0.1"},
+							key:     value,
+						})
+						So(errs.HasErrors(), ShouldBeTrue)
+					}
+				}
+			})
+			Convey("So config policy should reject a missing url", func() {
+				_, errs := configPolicy.Get([]string{"intel", "cassandra"}).Process(map[string]ctypes.ConfigValue{})
+				So(errs.HasErrors(), ShouldBeTrue)
+			})
+			Convey("So config policy should reject a string port", func() {
+				_, errs := configPolicy.Get([]string{"intel", "cassandra"}).Process(map[string]ctypes.ConfigValue{
+					CassURL: ctypes.ConfigValueStr{Value: "127.0.0.1"},
+					Port:    ctypes.ConfigValueStr{Value: "8082"},
+				})
+				So(errs.HasErrors(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// downTransport fails every request as an unreachable node does
+type downTransport struct {
+	fakeTransport
+}
+
+func (d *downTransport) ReadMBean(objectname string) ([]Attribute, error) {
+	d.reads++
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+}
+
+func (d *downTransport) ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error) {
+	d.reads++
+	return nil, nil, &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+}
+
+func TestCollectMetrics(t *testing.T) {
+	Convey("Given a collector of several nodes", t, func() {
+		down := &downTransport{*newFakeTransport()}
+		cl := &cluster{clients: map[string]*CassClient{
+			"node1": newFakeClient(newFakeTransport()),
+			"node2": newFakeClient(newFakeTransport()),
+			"node3": newFakeClient(down),
+		}}
+		cl.clients["node2"].host = "node2"
+		cl.clients["node3"].host = "node3"
+
+		p := NewCassandraCollector()
+		p.clusters.clusters[configKey(nil)] = &registryEntry{cluster: cl}
+
+		mts := []plugin.MetricType{{
+			Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics",
+				"type", "Cache", "scope", "*", "name", "Hits", "Count"),
+		}}
+
+		Convey("a node which is down should be left out of the collection", func() {
+			metrics, err := p.CollectMetrics(mts)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 6)
+			for _, metric := range metrics {
+				So(metric.Namespace().Strings()[3], ShouldNotEqual, "node3")
+			}
+			So(down.reads, ShouldEqual, 1)
+		})
+
+		Convey("a cluster whose nodes are all down should fail the collection", func() {
+			cl.clients["node1"].transport = &downTransport{*newFakeTransport()}
+			cl.clients["node2"].transport = &downTransport{*newFakeTransport()}
+			metrics, err := p.CollectMetrics(mts)
+			So(metrics, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, CollectErrors{})
+
+			errs := err.(CollectErrors)
+			So(errs, ShouldHaveLength, 3)
+			for _, e := range errs {
+				So(e.Kind, ShouldEqual, ConnectionRefused)
+				So(e.NodeDown, ShouldBeTrue)
+				So(e.Namespace, ShouldEqual, mts[0].Namespace().String())
+			}
+		})
+
+		Convey("partial results should collect every node which is up", func() {
+			cl.partial = true
+			metrics, err := p.CollectMetrics(mts)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 7)
+
+			failed := metrics[len(metrics)-1]
+			So(failed.Namespace().String(), ShouldEqual, "/intel/cassandra/node/node3/"+ErrorMetric)
+			So(failed.Tags()["kind"], ShouldEqual, string(ConnectionRefused))
+			So(failed.Tags()["namespace"], ShouldEqual, mts[0].Namespace().String())
+		})
+
+		Convey("a node name should only collect that node", func() {
+			mts := []plugin.MetricType{{
+				Namespace_: core.NewNamespace("intel", "cassandra", "node", "node2", "org_apache_cassandra_metrics",
+					"type", "Cache", "scope", "KeyCache", "name", "Hits", "Count"),
+			}}
+			metrics, err := p.CollectMetrics(mts)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace().Strings()[3], ShouldEqual, "node2")
+		})
+	})
+}
+
+// closingTransport records whether it was closed
+type closingTransport struct {
+	fakeTransport
+	closed bool
+}
+
+func (c *closingTransport) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry", t, func() {
+		r := newRegistry(time.Hour)
+		cfg1 := cdata.NewNode()
+		cfg1.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1"})
+		cfg1.AddItem(Port, ctypes.ConfigValueInt{Value: 8082})
+		cfg2 := cdata.NewNode()
+		cfg2.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.2"})
+		cfg2.AddItem(Port, ctypes.ConfigValueInt{Value: 8082})
+
+		Convey("the same config should return the same cluster", func() {
+			cl1, err := r.get(cfg1)
+			So(err, ShouldBeNil)
+			cl2, err := r.get(cfg1)
+			So(err, ShouldBeNil)
+			So(cl2, ShouldEqual, cl1)
+		})
+
+		Convey("different configs should return different clusters", func() {
+			cl1, _ := r.get(cfg1)
+			cl2, _ := r.get(cfg2)
+			So(cl2, ShouldNotEqual, cl1)
+			So(r.clusters, ShouldHaveLength, 2)
+		})
+
+		Convey("idle clusters should be evicted and their transports closed", func() {
+			transport := &closingTransport{fakeTransport: *newFakeTransport()}
+			r.clusters[configKey(cfg1)] = &registryEntry{
+				cluster: &cluster{clients: map[string]*CassClient{"node1": newFakeClient(transport)}},
+				used:    time.Now().Add(-2 * time.Hour),
+			}
+			r.get(cfg2)
+			So(r.clusters, ShouldHaveLength, 1)
+			So(r.clusters, ShouldContainKey, configKey(cfg2))
+			So(transport.closed, ShouldBeTrue)
+		})
+
+		Convey("a config should be got while the cluster of another one is built", func() {
+			building := &registryEntry{used: time.Now()}
+			building.mutex.Lock()
+			defer building.mutex.Unlock()
+			r.clusters[configKey(cfg1)] = building
+
+			done := make(chan *cluster)
+			go func() {
+				cl, _ := r.get(cfg2)
+				done <- cl
+			}()
+			var cl *cluster
+			select {
+			case cl = <-done:
+			case <-time.After(5 * time.Second):
+			}
+			So(cl, ShouldNotBeNil)
 		})
 	})
 }
@@ -0,0 +1,253 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// MetricTypeFile the file of the metric types
+	MetricTypeFile = "CassandraMetricType.json"
+	// MetricAPIFile the file of the searchable tree
+	MetricAPIFile = "CassandraMetricAPI.json"
+	// CatalogVersionFile the file recording the versions of the node the tree was discovered on
+	CatalogVersionFile = "version.json"
+
+	// SchemaVersionAttr the attribute of StorageService holding the node's schema version
+	SchemaVersionAttr = "SchemaVersion"
+
+	StaleCatalogErr = "The catalog was discovered on another version or schema of the node"
+	SaveCatalogErr  = "The catalog could not be saved"
+	BuildCatalogErr = "The metric catalog could not be built from the node, the embedded catalog is listed"
+)
+
+// nodeVersion defines the release and schema versions of a node. A catalog
+// discovered on a node is valid as long as both versions stay the same.
+type nodeVersion struct {
+	Release string
+	Schema  string
+}
+
+// readNodeVersion returns the release and schema versions of the node read from StorageService
+func readNodeVersion(t Transport) (nodeVersion, error) {
+	attrs, err := t.ReadMBean(StorageServiceMBean)
+	if err != nil {
+		return nodeVersion{}, err
+	}
+	var v nodeVersion
+	for _, attr := range attrs {
+		if attr.Value == nil {
+			continue
+		}
+		switch attr.Name {
+		case ReleaseVersionAttr:
+			v.Release = fmt.Sprint(attr.Value)
+		case SchemaVersionAttr:
+			v.Schema = fmt.Sprint(attr.Value)
+		}
+	}
+	if v.Release == "" {
+		return v, errors.New(NoReleaseVersionErr)
+	}
+	return v, nil
+}
+
+// catalogPath returns the directory of the catalog of the release in the catalog directory
+func catalogPath(dir, release string) string {
+	return filepath.Join(dir, majorVersion(release))
+}
+
+// checkCatalogVersion returns an error if the catalog of the path has no
+// versions, or if it was discovered on another release or schema of the node
+func checkCatalogVersion(path string, v nodeVersion) error {
+	content, err := ioutil.ReadFile(filepath.Join(path, CatalogVersionFile))
+	if err != nil {
+		return err
+	}
+	var saved nodeVersion
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return err
+	}
+	if saved != v {
+		return errors.New(StaleCatalogErr)
+	}
+	return nil
+}
+
+// loadCatalog returns the tree saved in the catalog directory for the node's
+// version. An error is returned if there is none, or if it was discovered on
+// another release or schema of the node.
+func loadCatalog(dir string, v nodeVersion) (*node, error) {
+	path := catalogPath(dir, v.Release)
+	if err := checkCatalogVersion(path, v); err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(path, MetricAPIFile))
+	if err != nil {
+		return nil, err
+	}
+	var tree *node
+	if err := json.Unmarshal(content, &tree); err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return nil, errors.New(ReadDocErr)
+	}
+	return tree, nil
+}
+
+// loadMetricTypes returns the metric types saved in the catalog directory for
+// the node's version. An error is returned if there are none, or if they were
+// built on another release or schema of the node.
+func loadMetricTypes(dir string, v nodeVersion) ([]plugin.MetricType, error) {
+	path := catalogPath(dir, v.Release)
+	if err := checkCatalogVersion(path, v); err != nil {
+		return nil, err
+	}
+	return readMetricType(path)
+}
+
+// saveCatalog saves the tree of the MBeans in the catalog directory for the
+// node's version. The tree is written first and the versions last, so only a
+// complete catalog is ever loaded.
+func saveCatalog(dir string, v nodeVersion, mbeans []string) error {
+	path, err := prepareCatalog(dir, v, MetricTypeFile)
+	if err != nil {
+		return err
+	}
+	if err := writeMetricAPIs(filepath.Join(path, MetricAPIFile), newTree(mbeans)); err != nil {
+		return err
+	}
+	return writeCatalogVersion(path, v)
+}
+
+// saveMetricTypes saves the metric types in the catalog directory for the
+// node's version, next to its tree and as the tree is saved
+func saveMetricTypes(dir string, v nodeVersion, types []plugin.MetricType) error {
+	path, err := prepareCatalog(dir, v, MetricAPIFile)
+	if err != nil {
+		return err
+	}
+	if err := writeMetricTypes(path, types); err != nil {
+		return err
+	}
+	return writeCatalogVersion(path, v)
+}
+
+// prepareCatalog creates the directory of the catalog of the node's version
+// and returns it. If the catalog was saved for another release or schema, its
+// versions and the other file of the catalog are removed, so the versions
+// never vouch for a file of another release.
+func prepareCatalog(dir string, v nodeVersion, other string) (string, error) {
+	path := catalogPath(dir, v.Release)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+	if checkCatalogVersion(path, v) == nil {
+		return path, nil
+	}
+	for _, file := range []string{CatalogVersionFile, other} {
+		if err := os.Remove(filepath.Join(path, file)); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// writeCatalogVersion records the versions of the node the catalog was saved for
+func writeCatalogVersion(path string, v nodeVersion) error {
+	version, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(path, CatalogVersionFile), version)
+}
+
+// newTree returns the searchable tree of the MBeans
+func newTree(mbeans []string) *node {
+	tree := newNode(Root)
+	for _, mbean := range mbeans {
+		tree.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+	}
+	return tree
+}
+
+// saveCatalog saves the tree of the MBeans discovered on the node of the
+// version if the catalog directory is configured and the version is known
+func (cc *CassClient) saveCatalog(version nodeVersion, mbeans []string) {
+	if cc.catalogDir == "" || version.Release == "" {
+		return
+	}
+	if err := saveCatalog(cc.catalogDir, version, mbeans); err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "saveCatalog",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(SaveCatalogErr)
+	}
+}
+
+// saveMetricTypes saves the metric types built on the node of the version
+// if the catalog directory is configured
+func (cc *CassClient) saveMetricTypes(version nodeVersion, types []plugin.MetricType) {
+	if cc.catalogDir == "" {
+		return
+	}
+	if err := saveMetricTypes(cc.catalogDir, version, types); err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "saveMetricTypes",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(SaveCatalogErr)
+	}
+}
+
+// writeFileAtomic writes the file through a temporary file renamed over it,
+// so the file is either the previous one or the complete new one
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogDir(t *testing.T) {
+	Convey("Given a catalog directory", t, func() {
+		dir, err := ioutil.TempDir("", "catalog")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		transport := newFakeTransport()
+		version := nodeVersion{Release: "3.0.15", Schema: "59adb24e-f3cd-3e02-97f0-5b395827453f"}
+		transport.mbeans[StorageServiceMBean] = []Attribute{
+			{Name: ReleaseVersionAttr, Type: "java.lang.String", Value: version.Release},
+			{Name: SchemaVersionAttr, Type: "java.lang.String", Value: version.Schema},
+		}
+		mbeans := []string{"org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits"}
+		newClient := func() *CassClient {
+			cc := NewCassClient("node1", transport)
+			cc.catalogDir = dir
+			return cc
+		}
+
+		Convey("a saved catalog should be read back", func() {
+			So(saveCatalog(dir, version, mbeans), ShouldBeNil)
+			tree, err := loadCatalog(dir, version)
+			So(err, ShouldBeNil)
+			So(tree.Children[MetricDomain].Children["type"].Children, ShouldContainKey, "Cache")
+
+			files, _ := ioutil.ReadDir(filepath.Join(dir, "3.0"))
+			So(files, ShouldHaveLength, 2)
+		})
+
+		Convey("a catalog of another release or schema should be stale", func() {
+			So(saveCatalog(dir, version, mbeans), ShouldBeNil)
+			_, err := loadCatalog(dir, nodeVersion{Release: "3.0.16", Schema: version.Schema})
+			So(err, ShouldNotBeNil)
+			_, err = loadCatalog(dir, nodeVersion{Release: version.Release, Schema: "another"})
+			So(err.Error(), ShouldEqual, StaleCatalogErr)
+		})
+
+		Convey("the saved catalog should be read in preference to the embedded one", func() {
+			So(saveCatalog(dir, version, mbeans), ShouldBeNil)
+			cc := newClient()
+			So(cc.loadTree(), ShouldBeNil)
+			So(cc.Root.Children[MetricDomain].Children["type"].Children, ShouldNotContainKey, "ColumnFamily")
+		})
+
+		Convey("the embedded catalog should be read if the schema changed", func() {
+			So(saveCatalog(dir, nodeVersion{Release: version.Release, Schema: "another"}, mbeans), ShouldBeNil)
+			cc := newClient()
+			So(cc.loadTree(), ShouldBeNil)
+			So(cc.Root.Children[MetricDomain].Children["type"].Children, ShouldContainKey, "ColumnFamily")
+		})
+
+		Convey("the catalog discovered on a node of another version should be saved", func() {
+			transport.mbeans[StorageServiceMBean][0].Value = "5.0.2"
+			cc := newClient()
+			So(cc.loadTree(), ShouldBeNil)
+			tree, err := loadCatalog(dir, cc.version)
+			So(err, ShouldBeNil)
+			So(tree.Children[MetricDomain].Children["type"].Children, ShouldContainKey, "Cache")
+		})
+
+		Convey("the metric types of the directory should be read in preference to the embedded ones", func() {
+			types := []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics", "type", "Cache")}}
+			So(saveMetricTypes(dir, version, types), ShouldBeNil)
+			read, err := loadMetricTypes(dir, version)
+			So(err, ShouldBeNil)
+			So(read, ShouldHaveLength, 1)
+
+			_, err = loadMetricTypes(dir, nodeVersion{Release: "3.0.16", Schema: version.Schema})
+			So(err, ShouldNotBeNil)
+			_, err = loadMetricTypes(dir, nodeVersion{Release: version.Release, Schema: "another"})
+			So(err.Error(), ShouldEqual, StaleCatalogErr)
+		})
+
+		Convey("the tree saved for another release should be removed along with its versions", func() {
+			So(saveCatalog(dir, version, mbeans), ShouldBeNil)
+			types := []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics", "type", "Cache")}}
+			So(saveMetricTypes(dir, nodeVersion{Release: "3.0.16", Schema: version.Schema}, types), ShouldBeNil)
+			_, err := loadCatalog(dir, version)
+			So(err, ShouldNotBeNil)
+			_, err = os.Stat(filepath.Join(dir, "3.0", MetricAPIFile))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("the metric types should be built and saved if the directory has none", func() {
+			mbean := "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits"
+			peak := 0
+			server := newMX4JServer([]string{mbean}, &peak)
+			defer server.Close()
+
+			cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+			cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: strings.TrimPrefix(server.URL, "http://")})
+			cfg.AddItem(CatalogDir, ctypes.ConfigValueStr{Value: dir})
+			types, err := NewCassClient("node1", transport).getMetricType(cfg)
+			So(err, ShouldBeNil)
+			So(types, ShouldNotBeEmpty)
+
+			saved, err := loadMetricTypes(dir, nodeVersion{Release: "4.0.11"})
+			So(err, ShouldBeNil)
+			So(saved, ShouldHaveLength, len(types))
+		})
+
+		Convey("the metric types saved for another release should be rebuilt", func() {
+			mbean := "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits"
+			peak := 0
+			server := newMX4JServer([]string{mbean}, &peak)
+			defer server.Close()
+
+			stale := []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics", "type", "Stale")}}
+			So(saveMetricTypes(dir, nodeVersion{Release: "4.0.10"}, stale), ShouldBeNil)
+
+			cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+			cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: strings.TrimPrefix(server.URL, "http://")})
+			cfg.AddItem(CatalogDir, ctypes.ConfigValueStr{Value: dir})
+			types, err := NewCassClient("node1", transport).getMetricType(cfg)
+			So(err, ShouldBeNil)
+			for _, mt := range types {
+				So(mt.Namespace().Strings(), ShouldNotContain, "Stale")
+			}
+
+			_, err = loadMetricTypes(dir, nodeVersion{Release: "4.0.10"})
+			So(err, ShouldNotBeNil)
+			saved, err := loadMetricTypes(dir, nodeVersion{Release: "4.0.11"})
+			So(err, ShouldBeNil)
+			So(saved, ShouldHaveLength, len(types))
+		})
+
+		Convey("the embedded metric types should be listed if the node can't be discovered", func() {
+			embedded, _ := readMetricTypeAsset(defaultCatalog.types)
+			cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+			cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1:1"})
+			cfg.AddItem(CatalogDir, ctypes.ConfigValueStr{Value: dir})
+			types, err := NewCassClient("node1", transport).getMetricType(cfg)
+			So(err, ShouldBeNil)
+			So(len(types), ShouldBeGreaterThanOrEqualTo, len(embedded))
+
+			files, _ := ioutil.ReadDir(dir)
+			So(files, ShouldBeEmpty)
+		})
+	})
+}
@@ -19,69 +19,64 @@ limitations under the License.
 package cassandra
 
 import (
-	"encoding/xml"
 	"errors"
 	"io"
-	"io/ioutil"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
 	log "github.com/sirupsen/logrus"
 )
 
-// const defines constant varaibles
-const (
-	MetricQuery    = "/serverbydomain?querynames=org.apache.cassandra.metrics:*&template=identity"
-	MbeanQuery     = "/mbean?objectname="
-	QuerySuffix    = "&template=identity"
-	JavaStringType = "java.lang.String"
-)
-
-// XMLServer represents Server element
-type XMLServer struct {
-	XMLName xml.Name  `xml:"Server"`
-	Domain  XMLDomain `xml:"Domain"`
-}
-
-// XMLDomain represents Domain element
-type XMLDomain struct {
-	XMLName xml.Name   `xml:"Domain"`
-	MBeans  []XMLMBean `xml:"MBean"`
-}
-
-// XMLMBean represents MBean element
-type XMLMBean struct {
-	XMLName    xml.Name `xml:"MBean"`
-	Objectname string   `xml:"objectname,attr"`
-}
-
-//XMLAttributes represents list of Attribute elements
-type XMLAttributes struct {
-	XMLName    xml.Name       `xml:"MBean"`
-	Attributes []XMLAttribute `xml:"Attribute"`
-}
-
-// XMLAttribute represents Attribute element
-type XMLAttribute struct {
-	XMLName xml.Name `xml:"Attribute"`
-	Name    string   `xml:"name,attr"`
-	Type    string   `xml:"type,attr"`
-	Value   float64  `xml:"value,attr"`
-}
-
-// CassClient defines the URL of Cassandra
+// CassClient defines the Cassandra node and the transport to read it.
+// It may collect for several tasks at the same time: the collections share
+// the tree under the read lock of mutex, which is only write locked to add
+// MBeans into the tree, and lock the targets they read one by one.
 type CassClient struct {
-	client *HTTPClient
-	host   string
-	Root   *node
+	// cycles is first to be aligned for the atomic operations
+	cycles    uint64
+	transport Transport
+	host      string
+	cache     cachePolicy
+	timeout   time.Duration
+	mutex     sync.RWMutex
+	// jvmListed the JVM platform MBeans of the node were added into the tree
+	jvmListed bool
+	// states adds the state metrics of the enum and boolean attributes
+	states bool
+	// tags reports the keyspaces and tables as tags
+	tags bool
+	// encoding the encoding of the namespace elements
+	encoding string
+	// refreshInterval the interval the tree is merged with the node's MBeans at,
+	// refreshed the time of the last merge. A zero interval disables the merge.
+	refreshInterval time.Duration
+	refreshed       time.Time
+	// version the versions of the node, empty until they're read
+	version nodeVersion
+	// catalogDir the directory the catalogs discovered on the node are saved in
+	catalogDir string
+	Root       *node
 }
 
 // NewCassClient returns a new instance of CassClient
-func NewCassClient(url, host string) *CassClient {
+// reading the node through the transport
+func NewCassClient(host string, transport Transport) *CassClient {
 	return &CassClient{
-		client: NewHTTPClient(url, "", DefaultTimeout),
-		host:   host,
-		Root:   &node{Name: Root, Children: map[string]*node{}},
+		transport: transport,
+		host:      host,
+		encoding:  LegacyEncoding,
+		Root:      &node{Name: Root, Children: map[string]*node{}},
+	}
+}
+
+// close closes the transport of the client, if it holds connections
+func (cc *CassClient) close() {
+	if closer, ok := cc.transport.(io.Closer); ok {
+		closer.Close()
 	}
 }
 
@@ -90,106 +85,336 @@ func NewEmptyCassClient() *CassClient {
 	return &CassClient{}
 }
 
-// getMetricType returns all available metric types. It reads from
-// CassandraMetricType.json file.It builds metric list only when the file does not exist or it's empty.
+// getMetricType returns all available metric types. It reads from the
+// CassandraMetricType.json file saved in the catalog directory for the version
+// of the config's first node, if any, or from the embedded catalog of the
+// version. It builds metric list, and saves it in the catalog directory, when
+// the file does not exist, is empty or was built on another release or schema,
+// or when the version has no embedded catalog. The default
+// catalog is read if the version can't be read or the list can't be built.
+// The JVM catalog is always listed along with the Cassandra metrics.
+// In tag mode the keyspaces and tables are left out of the namespaces.
 func (cc *CassClient) getMetricType(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
-	types, err := readMetricType()
+	cl, err := newCluster(cfg)
 	if err != nil {
-		return cc.buildMetricType(cfg)
+		return nil, err
+	}
+	defer cl.close()
+	cc, err = cl.newClient(cl.seeds[0])
+	if err != nil {
+		return nil, err
+	}
+	defer cc.close()
+
+	types, err := cc.metricTypes()
+	if err != nil {
+		return nil, err
+	}
+	if getOptionalBool(cfg, TagMode, false) {
+		return untagMetricTypes(types), nil
 	}
 	return types, nil
 }
 
-// buildMetricType builds all metric types and write them into
-// CassandraMetricType.json file.
-func (cc *CassClient) buildMetricType(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
-	cc, err := initClient(cfg)
+// metricTypes returns the metric types of the node's version
+func (cc *CassClient) metricTypes() ([]plugin.MetricType, error) {
+	version, err := readNodeVersion(cc.transport)
 	if err != nil {
-		return nil, err
+		cassLog.WithFields(log.Fields{
+			"_block": "metricTypes",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(NoReleaseVersionErr)
+		return readDescribedMetricTypes(readMetricTypeAsset(defaultCatalog.types))
+	}
+
+	if cc.catalogDir != "" {
+		if types, err := readDescribedMetricTypes(loadMetricTypes(cc.catalogDir, version)); err == nil {
+			return types, nil
+		}
+	} else if c, ok := catalogs[majorVersion(version.Release)]; ok {
+		return readDescribedMetricTypes(readMetricTypeAsset(c.types))
 	}
 
-	resp, err := cc.client.httpClient.Get(cc.client.GetUrl() + MetricQuery)
+	types, err := cc.buildMetricType()
 	if err != nil {
-		return nil, err
+		cassLog.WithFields(log.Fields{
+			"_block": "metricTypes",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(BuildCatalogErr)
+		c, ok := catalogs[majorVersion(version.Release)]
+		if !ok {
+			c = defaultCatalog
+		}
+		return readDescribedMetricTypes(readMetricTypeAsset(c.types))
 	}
-	defer resp.Body.Close()
+	cc.saveMetricTypes(version, types)
+	return types, nil
+}
 
-	mbeans, err := readObjectname(resp.Body)
+// readDescribedMetricTypes returns the metric types read along with the JVM
+// and state metric types. The units and descriptions of the file are told
+// from the namespaces.
+func readDescribedMetricTypes(types []plugin.MetricType, err error) ([]plugin.MetricType, error) {
 	if err != nil {
 		return nil, err
 	}
+	return mergeMetricTypes(describeMetricTypes(types), append(jvmMetricTypes(), stateMetricTypes()...)), nil
+}
 
-	nspace := map[string]plugin.MetricType{}
-	for _, mbean := range mbeans {
-		// mbean.Objectname represents each callable measurement
-		ns, _ := cc.getElementTypes(mbean.Objectname)
-		for _, n := range ns {
-			nspace[n.Namespace().String()] = n
-		}
+// buildMetricType builds all metric types of the node
+func (cc *CassClient) buildMetricType() ([]plugin.MetricType, error) {
+	mbeans, err := cc.catalogMBeans()
+	if err != nil {
+		return nil, err
 	}
 
 	mtsType := []plugin.MetricType{}
-	for _, v := range nspace {
-		mtsType = append(mtsType, v)
+	for _, mbean := range mbeans {
+		// mbean represents each callable measurement
+		ns, _ := cc.getElementTypes(mbean)
+		mtsType = append(mtsType, ns...)
 	}
-
-	writeMetricTypes(mtsType)
-	return mtsType, nil
+	return mergeMetricTypes(mtsType, append(jvmMetricTypes(), stateMetricTypes()...)), nil
 }
 
-// buildMetricAPI builds the base searchable tree and write it
-// into CassandraMetricAPI.json file.
-func (cc *CassClient) buidMetricAPI() error {
-	resp, err := cc.client.httpClient.Get(cc.client.GetUrl() + MetricQuery)
+// catalogMBeans returns the MBeans of the metric catalog the node lists,
+// the metric MBeans along with the JVM and state MBeans
+func (cc *CassClient) catalogMBeans() ([]string, error) {
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if jvm, err := listJVMMBeans(cc.transport); err == nil {
+		mbeans = append(mbeans, jvm...)
+	}
+	return append(mbeans, stateMBeans...), nil
+}
+
+// mergeMetricTypes returns the metric types of both lists, each namespace once
+func mergeMetricTypes(types, more []plugin.MetricType) []plugin.MetricType {
+	seen := map[string]bool{}
+	merged := []plugin.MetricType{}
+	for _, mt := range append(types, more...) {
+		if !seen[mt.Namespace().String()] {
+			seen[mt.Namespace().String()] = true
+			merged = append(merged, mt)
+		}
+	}
+	return merged
+}
 
-	mbeans, err := readObjectname(resp.Body)
+// buildMetricAPI builds the base searchable tree from the MBeans the node
+// lists and saves it in the catalog directory, if any.
+func (cc *CassClient) buidMetricAPI() error {
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
 	if err != nil {
 		return err
 	}
 
 	for _, mbean := range mbeans {
-		nodes := makeLitteralNamespace(mbean.Objectname, "")
-		cc.Root.Add(nodes, 0, mbean.Objectname)
+		nodes := makeLitteralNamespace(mbean, "")
+		cc.Root.Add(nodes, 0, mbean)
 	}
-	writeMetricAPIs(cc.Root)
+	cc.refreshed = time.Now()
+	cc.saveCatalog(cc.version, mbeans)
 	return nil
 }
 
-// getElementTypes returns specific XML element namespace along with its unit
+// getElementTypes returns specific MBean attribute namespaces along with their units
+// and descriptions
 func (cc *CassClient) getElementTypes(url string) ([]plugin.MetricType, error) {
-	resp, err := cc.client.httpClient.Get(cc.client.GetUrl() + MbeanQuery + url + QuerySuffix)
+	leaves, err := cc.readLeaves(url)
 	if err != nil {
-		cassLog.WithFields(log.Fields{
-			"_block": "getTypes",
-			"error":  err,
-		}).Error(ReadDocErr)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return elementTypes(url, leaves), nil
+}
 
-	contents, err := ioutil.ReadAll(resp.Body)
-	if err != nil || string(contents) == EmptyRespErr {
+// readLeaves returns the attributes of the MBean with the items of the
+// composite and tabular attributes flattened into their own attributes
+func (cc *CassClient) readLeaves(url string) ([]Attribute, error) {
+	var attrs []Attribute
+	var err error
+	if lister, ok := cc.transport.(AttributeLister); ok {
+		attrs, err = lister.ListAttributes(url)
+	}
+	// the items of composite and tabular attributes are only known from their values
+	if attrs == nil || err != nil || hasOpenData(attrs) {
+		attrs, err = cc.transport.ReadMBean(url)
+	}
+	if err != nil {
 		cassLog.WithFields(log.Fields{
 			"_block": "getTypes",
 			"error":  err,
 		}).Error(QueryDocErr)
-		return nil, errors.New(QueryDocErr)
+		return nil, err
 	}
+	return flattenAttributes(attrs), nil
+}
 
-	attrs, _ := readXMLAttrbutes(contents)
+// elementTypes returns the namespaces of the collectable attributes of the
+// MBean. Their units and descriptions are told from the dynamic namespaces,
+// as the catalog lists them for all the MBeans they match, not only this one.
+func elementTypes(url string, leaves []Attribute) []plugin.MetricType {
 	ns := []plugin.MetricType{}
-	for _, attr := range attrs {
-		if attr.Type != JavaStringType {
-			ns = append(ns, plugin.MetricType{
-				Namespace_: makeDynamicNamespace(cc.host, url, attr.Name),
-				Unit_:      attr.Type,
+	for _, attr := range leaves {
+		if attr.collectable() {
+			ns = append(ns, describeMetricType(plugin.MetricType{
+				Namespace_: makeDynamicNamespace("", url, attr.Name),
+			}))
+		}
+	}
+	return ns
+}
+
+// newCycle starts a new collection cycle. The MBeans read in
+// previous cycles are read again unless they are cached.
+func (cc *CassClient) newCycle() *cycle {
+	c := &cycle{
+		id:        atomic.AddUint64(&cc.cycles, 1),
+		read:      map[*nodeTarget]bool{},
+		start:     time.Now(),
+		transport: cc.transport,
+		cache:     cc.cache,
+		states:    cc.states,
+		tags:      cc.tags,
+	}
+	if cc.timeout > 0 {
+		c.deadline = c.start.Add(cc.timeout)
+	}
+	return c
+}
+
+// collectMetrics collects the requested metrics of the node in a new cycle.
+// The metrics requested for another node name are skipped. The requested
+// namespaces are decoded into ObjectName parts and the collected ones are
+// encoded back, so any ObjectName value round-trips. Along with the
+// metrics which could be read it returns the error of every requested
+// namespace which could not be read completely.
+func (cc *CassClient) collectMetrics(mts []plugin.MetricType) ([]plugin.MetricType, CollectErrors) {
+	metrics := []plugin.MetricType{}
+	var errs CollectErrors
+
+	// every MBean is read at most once per collection
+	c := cc.newCycle()
+	if cc.refreshDue() {
+		cc.refreshTree(c)
+	}
+	searches := make([][]string, len(mts))
+	for i, m := range mts {
+		elems := m.Namespace().Strings()
+		search := decodeNamespace(elems, cc.encoding)
+		if len(search) > 4 && elems[4] != ErrorMetric && matchNodeName(cc.nodeElement(elems), cc.host) {
+			if search[4] == JVMDomain {
+				cc.addJVMTargets(c)
+			}
+			searches[i] = search[4:]
+		}
+	}
+
+	cc.mutex.RLock()
+	defer cc.mutex.RUnlock()
+	cc.prefetch(c, searches)
+
+	for i, m := range mts {
+		if searches[i] == nil {
+			continue
+		}
+		results := []nodeData{}
+		if err := cc.Root.Get(c, searches[i], 0, &results); err != nil {
+			ce := newCollectError(err, "")
+			ce.Node = cc.host
+			ce.Namespace = m.Namespace().String()
+			errs = append(errs, ce)
+		}
+
+		for _, result := range results {
+			path, tags := result.elements(), map[string]string(nil)
+			if cc.tags {
+				path, tags = taggedNamespace(result)
+			}
+			ns := append([]string{"intel", "cassandra", "node"}, encodeNamespace(append([]string{cc.host}, path...), cc.encoding)...)
+			metrics = append(metrics, plugin.MetricType{
+				Namespace_:   core.NewNamespace(ns...),
+				Timestamp_:   time.Now(),
+				Data_:        result.Data,
+				Unit_:        result.Unit,
+				Description_: result.Description,
+				Tags_:        tags,
 			})
 		}
 	}
-	return ns, nil
+
+	if c.err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "collectMetrics",
+			"node":   cc.host,
+			"error":  c.err,
+		}).Error(NodeDownErr)
+		for _, e := range errs {
+			e.NodeDown = true
+		}
+	}
+	return metrics, errs
+}
+
+// nodeElement returns the requested node name element, which is
+// only decoded along with the rest of the namespace when it's escaped
+func (cc *CassClient) nodeElement(elems []string) string {
+	if cc.encoding == LegacyEncoding {
+		return elems[3]
+	}
+	return decodeNamespace(elems[3:4], cc.encoding)[0]
+}
+
+// collect returns the data points matching the search path in the cycle.
+// The MBeans the path resolves to are read up front, so that a transport
+// supporting bulk reads serves a wildcard with a single request.
+func (cc *CassClient) collect(c *cycle, names []string, results *[]nodeData) error {
+	cc.mutex.RLock()
+	defer cc.mutex.RUnlock()
+	cc.prefetch(c, [][]string{names})
+	return cc.Root.Get(c, names, 0, results)
+}
+
+// prefetch reads the MBeans all search paths resolve to with one call of the
+// transport, which reads them concurrently or with a single bulk request.
+// Only the reads are concurrent, the attributes are added into the tree
+// once they're all read, so the tree is only changed by the collecting goroutine.
+func (cc *CassClient) prefetch(c *cycle, searches [][]string) {
+	targets := map[string]*node{}
+	for _, names := range searches {
+		if names != nil {
+			cc.Root.findTargets(c, names, 0, targets)
+		}
+	}
+
+	if len(targets) > 1 && c.check() == nil {
+		objectnames := []string{}
+		for uri := range targets {
+			objectnames = append(objectnames, uri)
+		}
+
+		attrs, errs, err := cc.transport.ReadMBeans(objectnames)
+		if err != nil {
+			c.failed(err)
+		}
+		for uri, target := range targets {
+			attr, ok := attrs[uri]
+			switch {
+			case err != nil:
+				target.storeRead(c, nil, err)
+			case errs[uri] != nil:
+				target.storeRead(c, nil, errs[uri])
+			case !ok:
+				target.storeRead(c, nil, errMBeanNotFound(uri))
+			default:
+				target.storeRead(c, attr, nil)
+			}
+		}
+	}
 }
 
 // getQueryURL returns the MX4J URL from the giving metric namespace
@@ -210,25 +435,9 @@ func (cc *CassClient) getQueryURL(ns []string) (string, error) {
 
 	// Builds MX4J query URL and
 	// ignores the last one while building the url params
-	params := []string{}
+	on := &ObjectName{Domain: ns[4]}
 	for i := 5; i < len(ns)-1; i = (i + 2) {
-		params = append(params, ns[i]+"="+ns[i+1])
+		on.Properties = append(on.Properties, Property{Key: ns[i], Value: ns[i+1]})
 	}
-	url := ns[4] + ":" + strings.Join(params, ",")
-	return url, nil
-}
-
-func readObjectname(reader io.Reader) ([]XMLMBean, error) {
-	var xmlServer XMLServer
-	err := xml.NewDecoder(reader).Decode(&xmlServer)
-	if err != nil {
-		return nil, err
-	}
-	return xmlServer.Domain.MBeans, nil
-}
-
-func readXMLAttrbutes(content []byte) ([]XMLAttribute, error) {
-	var xmlAttributes XMLAttributes
-	xml.Unmarshal(content, &xmlAttributes)
-	return xmlAttributes.Attributes, nil
+	return on.String(), nil
 }
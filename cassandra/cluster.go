@@ -0,0 +1,650 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/config"
+	"github.com/intelsdi-x/snap/control/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// StorageServiceMBean reports the nodes of the ring as seen by the gossiper
+	StorageServiceMBean = "org.apache.cassandra.db:type=StorageService"
+	// FailureDetectorMBean reports the state of every known endpoint
+	FailureDetectorMBean = "org.apache.cassandra.net:type=FailureDetector"
+
+	NoPeersErr       = "No peers found through gossip"
+	MissingSecretEnv = "The environment variable of the secret is not set"
+
+	// DefaultDiscoveryInterval the default interval of the peer discovery
+	DefaultDiscoveryInterval = time.Minute
+	// EndpointTTL the time the looked up names of a node are cached for
+	EndpointTTL = 10 * time.Minute
+)
+
+// cluster defines the Cassandra nodes collected by one task. The nodes are
+// configured as a list, and if discovery is enabled, the list serves as
+// seeds to find the other nodes of the ring through gossip.
+type cluster struct {
+	port           int
+	transport      string
+	cache          cachePolicy
+	http           HTTPConfig
+	collectTimeout time.Duration
+	// partial returns the metrics which could be read even if some namespaces failed
+	partial bool
+	// states adds the state metrics of the enum and boolean attributes
+	states bool
+	// tags reports the keyspaces and tables as tags
+	tags bool
+	// encoding the encoding of the namespace elements
+	encoding string
+	// concurrency the maximum number of MBeans of a node read at the same time
+	concurrency int
+	// refresh the interval the trees are merged with the MBeans of the nodes at
+	refresh time.Duration
+	// catalogDir the directory the catalogs discovered on the nodes are saved in
+	catalogDir string
+	// secrets the credentials redacted from the log while the cluster is used
+	secrets []string
+
+	seeds []string
+	// mutex guards the clients and the time of the last discovery, as
+	// the cluster may be collected for several tasks at the same time
+	mutex       sync.RWMutex
+	clients     map[string]*CassClient
+	discovery   bool
+	interval    time.Duration
+	discovered  time.Time
+	discovering bool
+
+	// endpoints caches the endpoints of the nodes by their urls,
+	// so the names of the nodes aren't looked up on every discovery
+	endpointMutex sync.Mutex
+	endpoints     map[string]endpoint
+}
+
+// endpoint defines the key, the address and the name of a node,
+// along with the time its names were looked up
+type endpoint struct {
+	key      string
+	server   string
+	name     string
+	resolved time.Time
+}
+
+// newCluster returns the cluster defined by the config. The nodes are listed
+// in the url config item separated by commas and may define their own port.
+func newCluster(cfg interface{}) (*cluster, error) {
+	items, err := config.GetConfigItems(cfg, CassURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// the durations are validated before any secret is read
+	requestTimeout, err := getDuration(cfg, RequestTimeout, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := getDuration(cfg, CacheTTL, 0)
+	if err != nil {
+		return nil, err
+	}
+	collectTimeout, err := getDuration(cfg, CollectTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := getDuration(cfg, DiscoveryInterval, DefaultDiscoveryInterval)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := getDuration(cfg, RefreshInterval, DefaultRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	transport := getOptionalString(cfg, CassTransport, MX4JTransport)
+	port, err := defaultPort(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	http, err := newHTTPConfig(cfg, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := newCachePolicy(ttl, getOptionalString(cfg, CacheMBeans, ".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	cl := &cluster{
+		port:           getOptionalInt(cfg, Port, port),
+		transport:      transport,
+		cache:          cache,
+		http:           http,
+		collectTimeout: collectTimeout,
+		clients:        map[string]*CassClient{},
+		discovery:      getOptionalBool(cfg, Discovery, false),
+		partial:        getOptionalBool(cfg, PartialResults, false),
+		states:         getOptionalBool(cfg, StateMetrics, false),
+		tags:           getOptionalBool(cfg, TagMode, false),
+		encoding:       getOptionalString(cfg, NamespaceEncoding, LegacyEncoding),
+		concurrency:    getOptionalInt(cfg, Concurrency, DefaultConcurrency),
+		interval:       interval,
+		refresh:        refresh,
+		catalogDir:     getOptionalString(cfg, CatalogDir, ""),
+	}
+	for _, url := range strings.Split(items[CassURL].(string), ",") {
+		url = strings.TrimSpace(url)
+		if url != "" {
+			cl.seeds = append(cl.seeds, url)
+		}
+	}
+
+	if len(cl.seeds) == 0 {
+		return nil, errors.New(InvalidURL)
+	}
+	if err := validEncoding(cl.encoding); err != nil {
+		return nil, err
+	}
+	cl.secrets = http.secrets()
+	addSecrets(cl.secrets)
+	return cl, nil
+}
+
+// defaultPort returns the port the transport listens on by default
+func defaultPort(transport string) (int, error) {
+	switch transport {
+	case MX4JTransport:
+		return DefaultPort, nil
+	case JolokiaTransport:
+		return DefaultJolokiaPort, nil
+	}
+	return 0, errors.New(InvalidTransport)
+}
+
+// newHTTPConfig returns the HTTP settings defined by the config
+func newHTTPConfig(cfg interface{}, timeout time.Duration) (HTTPConfig, error) {
+	http := HTTPConfig{
+		Scheme:  getOptionalString(cfg, Scheme, HTTPScheme),
+		Timeout: timeout,
+	}
+
+	switch http.Scheme {
+	case HTTPScheme:
+	case HTTPSScheme:
+		tc := TLSConfig{
+			CAFile:             getOptionalString(cfg, CAFile, ""),
+			CertFile:           getOptionalString(cfg, CertFile, ""),
+			KeyFile:            getOptionalString(cfg, KeyFile, ""),
+			ServerName:         getOptionalString(cfg, ServerName, ""),
+			InsecureSkipVerify: getOptionalBool(cfg, InsecureSkipVerify, false),
+		}
+		tls, err := tc.Load()
+		if err != nil {
+			return http, err
+		}
+		if tc.InsecureSkipVerify {
+			cassLog.WithField("_block", "newHTTPConfig").Warn("TLS certificate verification is disabled")
+		}
+		http.TLS = tls
+	default:
+		return http, errors.New(InvalidScheme)
+	}
+
+	var err error
+	http.Username = getOptionalString(cfg, Username, "")
+	http.Password, err = readSecret(cfg, Password, PasswordFile, PasswordEnv)
+	if err != nil {
+		return http, err
+	}
+	http.Token, err = readSecret(cfg, Token, TokenFile, TokenEnv)
+	if err != nil {
+		return http, err
+	}
+	if http.Username != "" && http.Token != "" {
+		return http, errors.New(AuthConflict)
+	}
+	if http.Username == "" && http.Password != "" {
+		return http, errors.New(PasswordWithoutUser)
+	}
+	return http, nil
+}
+
+// secrets returns the credentials of the config, along with
+// the encoded credentials of basic authentication
+func (http HTTPConfig) secrets() []string {
+	secrets := []string{http.Password, http.Token}
+	if http.Username != "" {
+		secrets = append(secrets, base64.StdEncoding.EncodeToString([]byte(http.Username+":"+http.Password)))
+	}
+	return secrets
+}
+
+// readSecret returns the secret given either in the config item of the key,
+// in the file named by the fileKey item or in the environment variable named
+// by the envKey item.
+func readSecret(cfg interface{}, key, fileKey, envKey string) (string, error) {
+	secret := getOptionalString(cfg, key, "")
+	if file := getOptionalString(cfg, fileKey, ""); file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		secret = strings.TrimRight(string(b), "\r\n")
+	}
+	if env := getOptionalString(cfg, envKey, ""); env != "" {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("%s: %s", MissingSecretEnv, env)
+		}
+		secret = v
+	}
+	return secret, nil
+}
+
+// endpoint returns the key identifying the node, the address of its MX4J or
+// Jolokia endpoint and its name. The key is based on the IP address, so that
+// a seed configured by its host name and the same node found through
+// gossip are collected once. The endpoints are looked up again once they're
+// older than EndpointTTL.
+func (cl *cluster) endpoint(url string) (key, server, name string) {
+	cl.endpointMutex.Lock()
+	ep, ok := cl.endpoints[url]
+	cl.endpointMutex.Unlock()
+	if ok && time.Since(ep.resolved) < EndpointTTL {
+		return ep.key, ep.server, ep.name
+	}
+
+	key, server, name = lookupEndpoint(url, cl.port)
+
+	now := time.Now()
+	cl.endpointMutex.Lock()
+	defer cl.endpointMutex.Unlock()
+	if cl.endpoints == nil {
+		cl.endpoints = map[string]endpoint{}
+	}
+	for u, ep := range cl.endpoints {
+		if now.Sub(ep.resolved) >= EndpointTTL {
+			delete(cl.endpoints, u)
+		}
+	}
+	cl.endpoints[url] = endpoint{key: key, server: server, name: name, resolved: now}
+	return key, server, name
+}
+
+// lookupEndpoint looks up the key, the address and the name of the node
+func lookupEndpoint(url string, defaultPort int) (key, server, name string) {
+	host, port := url, strconv.Itoa(defaultPort)
+	if h, p, err := net.SplitHostPort(url); err == nil {
+		host, port = h, p
+	}
+
+	name = host
+	if names, err := net.LookupAddr(host); err == nil && len(names) > 0 {
+		name = names[0]
+	}
+
+	ip := host
+	if ips, err := net.LookupHost(host); err == nil && len(ips) > 0 {
+		ip = ips[0]
+	}
+	return net.JoinHostPort(ip, port), net.JoinHostPort(host, port), name
+}
+
+// newClient returns a client of the node
+func (cl *cluster) newClient(url string) (*CassClient, error) {
+	_, server, name := cl.endpoint(url)
+	transport, err := newTransport(cl.transport, server, cl.http, cl.concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	cc := NewCassClient(name, transport)
+	cc.cache = cl.cache
+	cc.timeout = cl.collectTimeout
+	cc.states = cl.states
+	cc.tags = cl.tags
+	cc.encoding = cl.encoding
+	cc.refreshInterval = cl.refresh
+	cc.catalogDir = cl.catalogDir
+	return cc, nil
+}
+
+// addClient adds a client of the node along with its searchable tree
+func (cl *cluster) addClient(url string) error {
+	cc, err := cl.newClient(url)
+	if err != nil {
+		return err
+	}
+
+	// reads the searchable tree of the node's version
+	if err := cc.loadTree(); err != nil {
+		return err
+	}
+
+	cc.addStateTargets()
+
+	key, _, _ := cl.endpoint(url)
+	cl.mutex.Lock()
+	cl.clients[key] = cc
+	cl.mutex.Unlock()
+	return nil
+}
+
+// withoutDownNodes returns the errors of the nodes which were collected,
+// leaving out the ones of the nodes which are down unless every node is,
+// along with the names of the nodes left out. So a dead node doesn't fail
+// the collection of the others, but a cluster which is down does.
+func (cl *cluster) withoutDownNodes(errs CollectErrors) (CollectErrors, []string) {
+	down := map[string]bool{}
+	for _, e := range errs {
+		if e.NodeDown {
+			down[e.Node] = true
+		}
+	}
+	if len(down) == 0 || len(down) >= len(cl.nodes()) {
+		return errs, nil
+	}
+
+	up := CollectErrors{}
+	for _, e := range errs {
+		if !e.NodeDown {
+			up = append(up, e)
+		}
+	}
+	nodes := []string{}
+	for node := range down {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return up, nodes
+}
+
+// init adds the clients of the seeds in parallel, as their trees are loaded.
+// The first error of the seeds is returned.
+func (cl *cluster) init() error {
+	errs := make([]error, len(cl.seeds))
+	var wg sync.WaitGroup
+	for i, seed := range cl.seeds {
+		wg.Add(1)
+		go func(i int, seed string) {
+			defer wg.Done()
+			errs[i] = cl.addClient(seed)
+		}(i, seed)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectMetrics collects the metrics of every node concurrently. A node which
+// is down or slow doesn't stop the collection of the others, its failed
+// namespaces are returned along with the metrics of all nodes.
+func (cl *cluster) collectMetrics(mts []plugin.MetricType) ([]plugin.MetricType, CollectErrors) {
+	if cl.discoveryDue() {
+		go cl.discoverInBackground()
+	}
+
+	metrics := []plugin.MetricType{}
+	var errs CollectErrors
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, cc := range cl.nodes() {
+		wg.Add(1)
+		go func(cc *CassClient) {
+			defer wg.Done()
+			mets, es := cc.collectMetrics(mts)
+
+			mutex.Lock()
+			metrics = append(metrics, mets...)
+			errs = append(errs, es...)
+			mutex.Unlock()
+		}(cc)
+	}
+	wg.Wait()
+	return metrics, errs
+}
+
+// discoveryDue returns true if the nodes of the ring should be discovered.
+// Only one of the collections running at the same time discovers them,
+// and not while the previous discovery is still running.
+func (cl *cluster) discoveryDue() bool {
+	if !cl.discovery {
+		return false
+	}
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	if cl.discovering || time.Since(cl.discovered) < cl.interval {
+		return false
+	}
+	cl.discovered = time.Now()
+	cl.discovering = true
+	return true
+}
+
+// discoverInBackground discovers the nodes of the ring while the nodes known
+// so far are collected, the nodes added are collected by the next collections
+func (cl *cluster) discoverInBackground() {
+	cl.discover()
+	cl.mutex.Lock()
+	cl.discovering = false
+	cl.mutex.Unlock()
+}
+
+// close closes the transports of the nodes and
+// stops redacting the secrets of the cluster
+func (cl *cluster) close() {
+	for _, cc := range cl.nodes() {
+		cc.close()
+	}
+	removeSecrets(cl.secrets)
+}
+
+// nodes returns the clients of the nodes
+func (cl *cluster) nodes() []*CassClient {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	clients := []*CassClient{}
+	for _, cc := range cl.clients {
+		clients = append(clients, cc)
+	}
+	return clients
+}
+
+// discover reads the nodes of the ring from the first node answering, adds
+// the new nodes which are up and removes the ones which left the ring. The
+// unreachable nodes are still members of the ring, so they're kept but not
+// added. The seeds are always kept. If no node answers, the nodes are kept as
+// they are.
+func (cl *cluster) discover() {
+	var peers, unreachable []string
+	var err error
+	for _, cc := range cl.nodes() {
+		peers, unreachable, err = discoverPeers(cc.transport)
+		if err == nil {
+			break
+		}
+	}
+	if len(peers) == 0 && len(unreachable) == 0 {
+		cassLog.WithFields(log.Fields{
+			"_block": "discover",
+			"error":  err,
+		}).Warn(NoPeersErr)
+		return
+	}
+
+	keep := map[string]bool{}
+	for _, member := range append(append([]string{}, cl.seeds...), unreachable...) {
+		key, _, _ := cl.endpoint(member)
+		keep[key] = true
+	}
+	// the new nodes are added in parallel, as their trees are loaded
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		key, _, _ := cl.endpoint(peer)
+		keep[key] = true
+		cl.mutex.RLock()
+		_, ok := cl.clients[key]
+		cl.mutex.RUnlock()
+		if ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			err := cl.addClient(peer)
+			if err != nil {
+				cassLog.WithFields(log.Fields{
+					"_block": "discover",
+					"node":   peer,
+					"error":  err,
+				}).Error("Adding the discovered node failed")
+				return
+			}
+			cassLog.WithFields(log.Fields{
+				"_block": "discover",
+				"node":   peer,
+			}).Info("Discovered node added")
+		}(peer)
+	}
+	wg.Wait()
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	for key, cc := range cl.clients {
+		if !keep[key] {
+			delete(cl.clients, key)
+			cc.close()
+			cassLog.WithFields(log.Fields{
+				"_block": "discover",
+				"node":   key,
+			}).Info("Node left the ring and was removed")
+		}
+	}
+}
+
+// discoverPeers returns the addresses of the live and joining nodes, and of
+// the unreachable nodes, read from StorageService, or of the nodes which are
+// up and down read from FailureDetector if StorageService can't be read.
+func discoverPeers(t Transport) ([]string, []string, error) {
+	attrs, err := t.ReadMBean(StorageServiceMBean)
+	if err == nil {
+		peers := []string{}
+		unreachable := []string{}
+		for _, attr := range attrs {
+			switch attr.Name {
+			case "LiveNodes", "JoiningNodes":
+				peers = append(peers, parseList(attr.Text)...)
+			case "UnreachableNodes":
+				unreachable = append(unreachable, parseList(attr.Text)...)
+			}
+		}
+		if len(peers) > 0 || len(unreachable) > 0 {
+			return cleanPeers(peers), cleanPeers(unreachable), nil
+		}
+	}
+
+	attrs, err = t.ReadMBean(FailureDetectorMBean)
+	if err != nil {
+		return nil, nil, err
+	}
+	peers := []string{}
+	unreachable := []string{}
+	for _, attr := range attrs {
+		if attr.Name == "SimpleStates" {
+			for peer, state := range parseMap(attr.Text) {
+				if state == "UP" {
+					peers = append(peers, peer)
+				} else {
+					unreachable = append(unreachable, peer)
+				}
+			}
+		}
+	}
+	if len(peers) == 0 && len(unreachable) == 0 {
+		return nil, nil, errors.New(NoPeersErr)
+	}
+	return cleanPeers(peers), cleanPeers(unreachable), nil
+}
+
+// cleanPeers strips the leading slash of InetAddress strings
+// and the gossip port from the addresses.
+func cleanPeers(peers []string) []string {
+	clean := []string{}
+	for _, peer := range peers {
+		peer = strings.TrimPrefix(peer, Slash)
+		if i := strings.LastIndex(peer, Slash); i >= 0 {
+			// host name/IP address
+			peer = peer[i+1:]
+		}
+		if host, _, err := net.SplitHostPort(peer); err == nil {
+			peer = host
+		}
+		if peer != "" {
+			clean = append(clean, peer)
+		}
+	}
+	return clean
+}
+
+// parseList parses a Java list rendered as [a, b, c]
+func parseList(s string) []string {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseMap parses a Java map rendered as {a=1, b=2}
+func parseMap(s string) map[string]string {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "{"), "}")
+	m := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m
+}
@@ -0,0 +1,212 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiscoverPeers(t *testing.T) {
+	Convey("Given a node reporting the ring through StorageService", t, func() {
+		transport := &fakeTransport{mbeans: map[string][]Attribute{
+			StorageServiceMBean: {
+				{Name: "LiveNodes", Type: "java.util.List", Text: "[127.0.0.1, 127.0.0.2]"},
+				{Name: "JoiningNodes", Type: "java.util.List", Text: "[127.0.0.3:7000]"},
+				{Name: "UnreachableNodes", Type: "java.util.List", Text: "[127.0.0.4]"},
+			},
+		}}
+
+		Convey("the live and joining nodes should be discovered along with the unreachable ones", func() {
+			peers, unreachable, err := discoverPeers(transport)
+			So(err, ShouldBeNil)
+			So(peers, ShouldResemble, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"})
+			So(unreachable, ShouldResemble, []string{"127.0.0.4"})
+		})
+	})
+
+	Convey("Given a node reporting the ring only through FailureDetector", t, func() {
+		transport := &fakeTransport{mbeans: map[string][]Attribute{
+			FailureDetectorMBean: {
+				{Name: "SimpleStates", Type: "java.util.Map", Text: "{/127.0.0.1=UP, /127.0.0.2=DOWN}"},
+			},
+		}}
+
+		Convey("the nodes which are up should be discovered along with the ones which are down", func() {
+			peers, unreachable, err := discoverPeers(transport)
+			So(err, ShouldBeNil)
+			So(peers, ShouldResemble, []string{"127.0.0.1"})
+			So(unreachable, ShouldResemble, []string{"127.0.0.2"})
+		})
+	})
+}
+
+func TestClusterInit(t *testing.T) {
+	Convey("Given a cluster of several seeds", t, func() {
+		cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+		cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1,127.0.0.2,127.0.0.3"})
+		cl, err := newCluster(cfg)
+		So(err, ShouldBeNil)
+		defer cl.close()
+
+		Convey("the clients of all seeds should be added", func() {
+			So(cl.init(), ShouldBeNil)
+			So(cl.clients, ShouldHaveLength, 3)
+		})
+	})
+}
+
+func TestNewCluster(t *testing.T) {
+	Convey("Given the config of a cluster", t, func() {
+		cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+		cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1"})
+
+		Convey("each transport should have its own default port", func() {
+			cl, err := newCluster(cfg)
+			So(err, ShouldBeNil)
+			So(cl.port, ShouldEqual, DefaultPort)
+
+			cfg.AddItem(CassTransport, ctypes.ConfigValueStr{Value: JolokiaTransport})
+			cl, err = newCluster(cfg)
+			So(err, ShouldBeNil)
+			So(cl.port, ShouldEqual, DefaultJolokiaPort)
+
+			cfg.AddItem(Port, ctypes.ConfigValueInt{Value: 9999})
+			cl, err = newCluster(cfg)
+			So(err, ShouldBeNil)
+			So(cl.port, ShouldEqual, 9999)
+		})
+
+		Convey("the durations should be numbers of seconds", func() {
+			cfg.AddItem(CollectTimeout, ctypes.ConfigValueInt{Value: 8})
+			cl, err := newCluster(cfg)
+			So(err, ShouldBeNil)
+			So(cl.collectTimeout, ShouldEqual, 8*time.Second)
+			So(cl.interval, ShouldEqual, DefaultDiscoveryInterval)
+		})
+
+		Convey("an unknown transport should be rejected", func() {
+			cfg.AddItem(CassTransport, ctypes.ConfigValueStr{Value: "jmx"})
+			_, err := newCluster(cfg)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("invalid durations should be rejected", func() {
+			for _, key := range []string{RequestTimeout, CacheTTL, CollectTimeout, DiscoveryInterval, RefreshInterval} {
+				for _, value := range []ctypes.ConfigValue{ctypes.ConfigValueStr{Value: "5m"}, ctypes.ConfigValueInt{Value: -1}} {
+					cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+					cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1"})
+					cfg.AddItem(key, value)
+					_, err := newCluster(cfg)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldStartWith, InvalidDuration+": "+key)
+				}
+			}
+		})
+	})
+}
+
+func TestClusterDiscover(t *testing.T) {
+	Convey("Given a cluster with discovery enabled", t, func() {
+		cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+		cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: "127.0.0.1"})
+		cfg.AddItem(Port, ctypes.ConfigValueInt{Value: 8082})
+		cfg.AddItem(Discovery, ctypes.ConfigValueBool{Value: true})
+
+		cl, err := newCluster(cfg)
+		So(err, ShouldBeNil)
+		So(cl.discovery, ShouldBeTrue)
+		So(cl.init(), ShouldBeNil)
+
+		transport := &fakeTransport{mbeans: map[string][]Attribute{
+			StorageServiceMBean: {
+				{Name: "LiveNodes", Type: "java.util.List", Text: "[127.0.0.1, 127.0.0.2, 127.0.0.3]"},
+			},
+		}}
+		for _, cc := range cl.clients {
+			cc.transport = transport
+		}
+
+		Convey("the nodes joining the ring should be added", func() {
+			cl.discover()
+			So(cl.clients, ShouldHaveLength, 3)
+
+			Convey("and the nodes leaving the ring should be removed except the seeds", func() {
+				transport.mbeans[StorageServiceMBean][0].Text = "[127.0.0.2]"
+				for _, cc := range cl.clients {
+					cc.transport = transport
+				}
+				cl.discover()
+				So(cl.clients, ShouldHaveLength, 2)
+				So(cl.clients, ShouldContainKey, "127.0.0.1:8082")
+				So(cl.clients, ShouldContainKey, "127.0.0.2:8082")
+			})
+
+			Convey("and the unreachable nodes should be kept", func() {
+				transport.mbeans[StorageServiceMBean] = []Attribute{
+					{Name: "LiveNodes", Type: "java.util.List", Text: "[127.0.0.1, 127.0.0.2]"},
+					{Name: "UnreachableNodes", Type: "java.util.List", Text: "[127.0.0.3]"},
+				}
+				for _, cc := range cl.clients {
+					cc.transport = transport
+				}
+				cl.discover()
+				So(cl.clients, ShouldHaveLength, 3)
+				So(cl.clients, ShouldContainKey, "127.0.0.3:8082")
+			})
+		})
+
+		Convey("the unreachable nodes should not be added", func() {
+			transport.mbeans[StorageServiceMBean] = append(transport.mbeans[StorageServiceMBean],
+				Attribute{Name: "UnreachableNodes", Type: "java.util.List", Text: "[127.0.0.4]"})
+			cl.discover()
+			So(cl.clients, ShouldHaveLength, 3)
+			So(cl.clients, ShouldNotContainKey, "127.0.0.4:8082")
+		})
+
+		Convey("the endpoints of the nodes should be looked up once", func() {
+			cl.discover()
+			So(cl.endpoints, ShouldContainKey, "127.0.0.2")
+			cl.endpoints["127.0.0.2"] = endpoint{key: "cached", server: "cached", name: "cached", resolved: time.Now()}
+			key, _, _ := cl.endpoint("127.0.0.2")
+			So(key, ShouldEqual, "cached")
+		})
+
+		Convey("the nodes should be discovered in the background of the collection", func() {
+			cl.interval = time.Hour
+			So(cl.discoveryDue(), ShouldBeTrue)
+			So(cl.discoveryDue(), ShouldBeFalse)
+			cl.discovered = time.Time{}
+			So(cl.discoveryDue(), ShouldBeFalse)
+
+			cl.discoverInBackground()
+			So(cl.clients, ShouldHaveLength, 3)
+			cl.discovered = time.Time{}
+			So(cl.discoveryDue(), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,106 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// syncTransport serializes the calls of a transport which isn't safe for concurrent use
+type syncTransport struct {
+	mutex     sync.Mutex
+	transport Transport
+}
+
+func (s *syncTransport) ListMBeans(pattern string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.transport.ListMBeans(pattern)
+}
+
+func (s *syncTransport) ReadMBean(objectname string) ([]Attribute, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.transport.ReadMBean(objectname)
+}
+
+func (s *syncTransport) ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.transport.ReadMBeans(objectnames)
+}
+
+func TestConcurrentCollections(t *testing.T) {
+	Convey("Given a cluster collected for several tasks at the same time", t, func() {
+		newTransport := func() Transport {
+			transport := newFakeTransport()
+			transport.mbeans["java.lang:type=Threading"] = []Attribute{
+				{Name: "ThreadCount", Type: "int", Value: int64(42)},
+				{Name: "PeakThreadCount", Type: "int", Value: int64(50)},
+			}
+			return &syncTransport{transport: transport}
+		}
+		cl := &cluster{clients: map[string]*CassClient{
+			"node1": newFakeClient(newTransport()),
+			"node2": newFakeClient(newTransport()),
+		}}
+		cl.clients["node2"].host = "node2"
+		cl.clients["node2"].cache, _ = newCachePolicy(time.Minute, "KeyCache")
+
+		tasks := [][]plugin.MetricType{
+			{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics",
+				"type", "Cache", "scope", "*", "name", "Hits", "Count")}},
+			{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics",
+				"type", "Cache", "scope", "KeyCache", "name", "Hits", "*")}},
+			{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "java_lang",
+				"type", "Threading", "ThreadCount")}},
+		}
+
+		Convey("every collection should get all of its metrics", func() {
+			counts := make([]int, len(tasks)*4)
+			var wg sync.WaitGroup
+			for i := range counts {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						metrics, _ := cl.collectMetrics(tasks[i%len(tasks)])
+						if j == 0 || len(metrics) < counts[i] {
+							counts[i] = len(metrics)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			for i, count := range counts {
+				So(count, ShouldEqual, []int{6, 4, 2}[i%len(tasks)])
+			}
+		})
+	})
+}
@@ -0,0 +1,108 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"time"
+)
+
+// errDeadline is returned for the reads issued after the cycle's deadline
+var errDeadline = errors.New("Collection deadline exceeded")
+
+// cachePolicy defines which MBeans may be served from the tree
+// across collection cycles and for how long.
+type cachePolicy struct {
+	ttl    time.Duration
+	mbeans *regexp.Regexp
+}
+
+// newCachePolicy returns a policy caching the MBeans matching
+// the regular expression for the ttl. A zero ttl disables caching.
+func newCachePolicy(ttl time.Duration, mbeans string) (cachePolicy, error) {
+	if ttl <= 0 {
+		return cachePolicy{}, nil
+	}
+	re, err := regexp.Compile(mbeans)
+	if err != nil {
+		return cachePolicy{}, err
+	}
+	return cachePolicy{ttl: ttl, mbeans: re}, nil
+}
+
+// ttlOf returns how long the attributes of the MBean may be served
+func (cp cachePolicy) ttlOf(objectname string) time.Duration {
+	if cp.ttl <= 0 || !cp.mbeans.MatchString(objectname) {
+		return 0
+	}
+	return cp.ttl
+}
+
+// cycle defines one collection cycle. Within a cycle every MBean is read
+// at most once, so overlapping queries share a request, and every cycle
+// reads the MBeans again unless the cache policy allows otherwise.
+// Once the node turns out to be unreachable or the deadline has passed
+// no further reads are issued in the cycle.
+type cycle struct {
+	id        uint64
+	start     time.Time
+	deadline  time.Time
+	transport Transport
+	cache     cachePolicy
+	// states adds the state metrics of the enum and boolean attributes
+	states bool
+	// tags passes through the tag keys missing from the searched paths
+	tags bool
+	err  error
+	// read the targets read in the cycle. A cycle is only used by the
+	// goroutine of its collection, so the set is not guarded.
+	read map[*nodeTarget]bool
+}
+
+// check returns an error if no more reads should be issued in the cycle
+func (c *cycle) check() error {
+	if c.err != nil {
+		return c.err
+	}
+	if !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		c.err = errDeadline
+	}
+	return c.err
+}
+
+// failed records the error of a read. A network error means the node is
+// unreachable, so the cycle gives up on the node.
+func (c *cycle) failed(err error) {
+	if _, ok := err.(net.Error); ok {
+		c.err = err
+	}
+}
+
+// fresh returns true if the target's attributes may be served in the cycle
+func (c *cycle) fresh(t *nodeTarget) bool {
+	return c.read[t] || c.start.Before(t.Expires)
+}
+
+// loaded marks the target's attributes as read in the cycle
+func (c *cycle) loaded(t *nodeTarget) {
+	c.read[t] = true
+	t.Expires = c.start.Add(c.cache.ttlOf(t.URI))
+}
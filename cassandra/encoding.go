@@ -0,0 +1,125 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// const defines constant varaibles
+const (
+	// EscapedEncoding encodes the namespace elements reversibly
+	EscapedEncoding = "escaped"
+	// LegacyEncoding reads every underscore of a requested namespace as a dot
+	// and reports the ObjectName values as they are
+	LegacyEncoding = "legacy"
+
+	InvalidEncoding = "Invalid namespace encoding"
+
+	escape = "%"
+)
+
+// encodeElement returns the namespace element of an ObjectName part. A dot
+// becomes an underscore, so the domains keep their familiar elements such as
+// org_apache_cassandra_metrics, and an underscore along with the bytes which
+// are not allowed in a namespace element are escaped as %XX, e.g. an
+// underscore as %5F and a slash as %2F. Every underscore of an element is
+// thus a dot, so no two parts share an element.
+func encodeElement(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.':
+			b.WriteString(Underscore)
+		case c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%s%02X", escape, c)
+		}
+	}
+	return b.String()
+}
+
+// decodeElement returns the ObjectName part of the namespace element
+// encoded by encodeElement. A malformed escape is kept as it is.
+func decodeElement(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '_':
+			b.WriteByte('.')
+		case c == '%' && i+2 < len(s):
+			n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteByte(byte(n))
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// validEncoding returns an error unless the encoding is known
+func validEncoding(encoding string) error {
+	if encoding != EscapedEncoding && encoding != LegacyEncoding {
+		return fmt.Errorf("%s: %s", InvalidEncoding, encoding)
+	}
+	return nil
+}
+
+// decodeNamespace returns the ObjectName parts searched by the elements of
+// a requested namespace. The wildcards and the names separated by pipes
+// are decoded one by one.
+func decodeNamespace(elems []string, encoding string) []string {
+	decoded := make([]string, len(elems))
+	for i, elem := range elems {
+		if encoding == LegacyEncoding {
+			decoded[i] = replaceUnderscoreToDot(elem)
+			continue
+		}
+		tokens := strings.Split(elem, Pipe)
+		for j, token := range tokens {
+			if token != Wildcard {
+				tokens[j] = decodeElement(token)
+			}
+		}
+		decoded[i] = strings.Join(tokens, Pipe)
+	}
+	return decoded
+}
+
+// encodeNamespace returns the namespace elements of the ObjectName parts
+func encodeNamespace(parts []string, encoding string) []string {
+	if encoding == LegacyEncoding {
+		return parts
+	}
+	encoded := make([]string, len(parts))
+	for i, part := range parts {
+		encoded[i] = encodeElement(part)
+	}
+	return encoded
+}
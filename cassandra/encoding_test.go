@@ -0,0 +1,84 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNamespaceEncoding(t *testing.T) {
+	Convey("Given ObjectName values", t, func() {
+		Convey("they should round-trip through the namespace elements", func() {
+			for _, value := range []string{
+				"org.apache.cassandra.metrics", "system_auth", "user__events", "/127.0.0.1",
+				"127_0_0_1", "G1 Young Generation", "%2F", "a.b_c", "a._b", "a_.b", "a__b", "a..b",
+			} {
+				So(decodeElement(encodeElement(value)), ShouldEqual, value)
+			}
+			So(encodeElement("org.apache.cassandra.metrics"), ShouldEqual, "org_apache_cassandra_metrics")
+			So(encodeElement("system_auth"), ShouldEqual, "system%5Fauth")
+			So(encodeElement("/127.0.0.1"), ShouldEqual, "%2F127_0_0_1")
+		})
+
+		Convey("an adjacent dot and underscore should not collide", func() {
+			So(encodeElement("a._b"), ShouldEqual, "a_%5Fb")
+			So(encodeElement("a_.b"), ShouldEqual, "a%5F_b")
+			So(encodeElement("a__b"), ShouldNotEqual, encodeElement("a..b"))
+		})
+
+		Convey("the IP scopes should not collide", func() {
+			So(encodeElement("/127.0.0.1"), ShouldNotEqual, encodeElement("127.0.0.1"))
+			So(encodeElement("127_0_0_1"), ShouldNotEqual, encodeElement("127.0.0.1"))
+		})
+	})
+
+	Convey("Given a keyspace with an underscore", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans["org.apache.cassandra.metrics:type=Keyspace,keyspace=system_auth,name=ReadLatency"] = []Attribute{
+			{Name: "Count", Type: "long", Value: int64(5)},
+		}
+		cc := newFakeClient(transport)
+		cc.encoding = EscapedEncoding
+		mts := []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*",
+			"org_apache_cassandra_metrics", "type", "Keyspace", "keyspace", "system%5Fauth", "name", "ReadLatency", "Count")}}
+
+		Convey("it should be requested by its escaped name", func() {
+			metrics, errs := cc.collectMetrics(mts)
+			So(errs, ShouldBeEmpty)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace().String(), ShouldEqual,
+				"/intel/cassandra/node/node1/org_apache_cassandra_metrics/type/Keyspace/keyspace/system%5Fauth/name/ReadLatency/Count")
+		})
+
+		Convey("the legacy encoding should report the ObjectName values as they are", func() {
+			cc.encoding = LegacyEncoding
+			metrics, _ := cc.collectMetrics([]plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*",
+				"org_apache_cassandra_metrics", "type", "Cache", "scope", "KeyCache", "name", "Hits", "Count")}})
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace().Strings()[4], ShouldEqual, "org.apache.cassandra.metrics")
+		})
+	})
+}
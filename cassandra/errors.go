@@ -0,0 +1,197 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+// ErrorKind classifies the errors of a collection
+type ErrorKind string
+
+// const defines constant varaibles
+const (
+	// ConnectionRefused the node refused the connection
+	ConnectionRefused ErrorKind = "connection_refused"
+	// Timeout a request or the collection of the node timed out
+	Timeout ErrorKind = "timeout"
+	// MBeanNotFound the node doesn't expose the MBean
+	MBeanNotFound ErrorKind = "mbean_not_found"
+	// MalformedResponse the response of the node could not be parsed
+	MalformedResponse ErrorKind = "malformed_response"
+	// RequestFailed any other failure
+	RequestFailed ErrorKind = "request_failed"
+
+	// ErrorMetric the name of the metric reporting a failed namespace
+	ErrorMetric = "error"
+)
+
+// CollectError is the error of one requested namespace of a node
+type CollectError struct {
+	Kind ErrorKind
+	// Node the name of the node
+	Node string
+	// Namespace the requested namespace
+	Namespace string
+	// MBean the object name of the MBean which could not be read, if known
+	MBean string
+	// NodeDown the node could not be reached, or not collected in time,
+	// so the collection of the node was given up
+	NodeDown bool
+	Err      error
+}
+
+// Error returns the description of the error
+func (e *CollectError) Error() string {
+	s := string(e.Kind)
+	if e.Node != "" {
+		s += " on node " + e.Node
+	}
+	if e.Namespace != "" {
+		s += " collecting " + e.Namespace
+	}
+	if e.MBean != "" {
+		s += " reading " + e.MBean
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// CollectErrors are the errors of the namespaces which failed in a collection
+type CollectErrors []*CollectError
+
+// Error returns the descriptions of all errors
+func (es CollectErrors) Error() string {
+	msgs := []string{}
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Sprintf("%d namespaces failed: %s", len(es), strings.Join(msgs, "; "))
+}
+
+// newCollectError returns the error classified by its cause.
+// A CollectError is copied with the MBean filled in if it's unknown.
+func newCollectError(err error, mbean string) *CollectError {
+	if ce, ok := err.(*CollectError); ok {
+		cp := *ce
+		if cp.MBean == "" {
+			cp.MBean = mbean
+		}
+		return &cp
+	}
+	return &CollectError{Kind: classifyError(err), MBean: mbean, Err: err}
+}
+
+// errMBeanNotFound returns the error of an MBean the node doesn't expose
+func errMBeanNotFound(objectname string) error {
+	return &CollectError{Kind: MBeanNotFound, MBean: objectname, Err: errors.New(QueryDocErr)}
+}
+
+// errMalformed returns the error of a response which could not be parsed
+func errMalformed(err error) error {
+	return &CollectError{Kind: MalformedResponse, Err: err}
+}
+
+// classifyError returns the kind of the error by unwrapping
+// the errors of the HTTP client and of the network.
+func classifyError(err error) ErrorKind {
+	switch err {
+	case errDeadline:
+		return Timeout
+	case io.EOF, io.ErrUnexpectedEOF:
+		return MalformedResponse
+	}
+	for {
+		switch e := err.(type) {
+		case *CollectError:
+			return e.Kind
+		case *url.Error:
+			if e.Timeout() {
+				return Timeout
+			}
+			err = e.Err
+		case *net.OpError:
+			if e.Timeout() {
+				return Timeout
+			}
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case syscall.Errno:
+			if e == syscall.ECONNREFUSED {
+				return ConnectionRefused
+			}
+			return RequestFailed
+		case net.Error:
+			if e.Timeout() {
+				return Timeout
+			}
+			return RequestFailed
+		case *xml.SyntaxError, *json.SyntaxError, *json.UnmarshalTypeError:
+			return MalformedResponse
+		default:
+			return RequestFailed
+		}
+	}
+}
+
+// errorMetric returns the metric reporting the failed namespace
+// with the failure's details in its tags
+func errorMetric(e *CollectError, encoding string) plugin.MetricType {
+	tags := map[string]string{
+		"namespace": e.Namespace,
+		"kind":      string(e.Kind),
+	}
+	if e.MBean != "" {
+		tags["mbean"] = e.MBean
+	}
+	return plugin.MetricType{
+		Namespace_: core.NewNamespace("intel", "cassandra", "node", encodeNamespace([]string{e.Node}, encoding)[0], ErrorMetric),
+		Timestamp_: time.Now(),
+		Data_:      e.Error(),
+		Unit_:      "string",
+		Tags_:      tags,
+	}
+}
+
+// errorMetricType returns the metric type of the failed namespaces
+func errorMetricType() plugin.MetricType {
+	return plugin.MetricType{
+		Namespace_: core.NewNamespace("intel", "cassandra", "node").
+			AddDynamicElement("nodeName", "The name of a Cassandra node").
+			AddStaticElement(ErrorMetric),
+		Unit_:        "string",
+		Description_: "A namespace which failed in a collection with partial results",
+	}
+}
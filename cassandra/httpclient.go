@@ -19,33 +19,169 @@ limitations under the License.
 package cassandra
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
 )
 
+// const defines constant varaibles
+const (
+	// HTTPScheme the scheme of plaintext endpoints
+	HTTPScheme = "http"
+	// HTTPSScheme the scheme of TLS endpoints
+	HTTPSScheme = "https"
+
+	InvalidScheme       = "Invalid scheme in Global configuration"
+	InvalidCAFile       = "No certificate found in the CA file"
+	AuthConflict        = "Either basic or bearer authentication may be configured, not both"
+	PasswordWithoutUser = "A password is configured without a username"
+)
+
 // HTTPClient defines the client for HTTP communication
 type HTTPClient struct {
 	url        string
+	scheme     string
 	httpClient *http.Client
 	endPoint   string
+	username   string
+	password   string
+	token      string
+}
+
+// HTTPConfig defines how a HTTPClient connects to the endpoint
+type HTTPConfig struct {
+	Scheme  string
+	Timeout time.Duration
+	// TLS the TLS settings of https endpoints
+	TLS *tls.Config
+	// Username and Password the credentials of basic authentication
+	Username string
+	Password string
+	// Token the token of bearer authentication
+	Token string
+}
+
+// TLSConfig defines the files and options the TLS settings are loaded from
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
 }
 
 // NewClient returns a new instance of HTTPClient
 func NewHTTPClient(url, endpoint string, timeout time.Duration) *HTTPClient {
+	return NewHTTPClientWithConfig(url, endpoint, HTTPConfig{Scheme: HTTPScheme, Timeout: timeout})
+}
+
+// NewHTTPClientWithConfig returns a new instance of HTTPClient
+// connecting to the endpoint as defined by the config
+func NewHTTPClientWithConfig(url, endpoint string, cfg HTTPConfig) *HTTPClient {
+	// every client has its own connections, so they're closed along with it
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     cfg.TLS,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	scheme := cfg.Scheme
+	if scheme == "" {
+		scheme = HTTPScheme
+	}
 	return &HTTPClient{
 		url:        url,
-		httpClient: &http.Client{Timeout: timeout},
+		scheme:     scheme,
+		httpClient: client,
 		endPoint:   endpoint,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		token:      cfg.Token,
 	}
 }
 
+// Load returns the TLS settings with the CA bundle and the client
+// certificate loaded from their files
+func (tc TLSConfig) Load() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+
+	if tc.CAFile != "" {
+		pem, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(InvalidCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
 // GetUrl returns the URL of a HTTPClient
 func (hc *HTTPClient) GetUrl() string {
 	u := url.URL{
-		Scheme: "http",
+		Scheme: hc.scheme,
 		Host:   hc.url,
 		Path:   hc.endPoint,
 	}
 	return u.String()
 }
+
+// Get issues a GET request to the path relative to the URL of the HTTPClient
+func (hc *HTTPClient) Get(path string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", hc.GetUrl()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return hc.do(req)
+}
+
+// Post issues a POST request to the URL of the HTTPClient
+func (hc *HTTPClient) Post(contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", hc.GetUrl(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return hc.do(req)
+}
+
+// Close closes the idle connections of the HTTPClient
+func (hc *HTTPClient) Close() {
+	if transport, ok := hc.httpClient.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// do sends the request with the credentials of the HTTPClient
+func (hc *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	switch {
+	case hc.token != "":
+		req.Header.Set("Authorization", "Bearer "+hc.token)
+	case hc.username != "":
+		req.SetBasicAuth(hc.username, hc.password)
+	}
+	return hc.httpClient.Do(req)
+}
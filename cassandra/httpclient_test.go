@@ -0,0 +1,219 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTPSClient(t *testing.T) {
+	Convey("Given a node serving HTTPS", t, func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "https://")
+
+		caFile, err := ioutil.TempFile("", "cassandra-ca")
+		So(err, ShouldBeNil)
+		defer os.Remove(caFile.Name())
+		pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		caFile.Close()
+
+		get := func(tc TLSConfig) error {
+			tls, err := tc.Load()
+			if err != nil {
+				return err
+			}
+			hc := NewHTTPClientWithConfig(host, "", HTTPConfig{Scheme: HTTPSScheme, Timeout: DefaultTimeout, TLS: tls})
+			resp, err := hc.Get("/")
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			return nil
+		}
+
+		Convey("the URL should use the https scheme", func() {
+			hc := NewHTTPClientWithConfig(host, JolokiaEndpoint, HTTPConfig{Scheme: HTTPSScheme})
+			So(hc.GetUrl(), ShouldEqual, "https://"+host+JolokiaEndpoint)
+		})
+
+		Convey("an unknown certificate should be rejected", func() {
+			So(get(TLSConfig{}), ShouldNotBeNil)
+		})
+
+		Convey("the certificate should be verified against the CA file", func() {
+			So(get(TLSConfig{CAFile: caFile.Name(), ServerName: "example.com"}), ShouldBeNil)
+		})
+
+		Convey("the verification may be disabled", func() {
+			So(get(TLSConfig{InsecureSkipVerify: true}), ShouldBeNil)
+		})
+
+		Convey("a CA file without certificates should be refused", func() {
+			_, err := TLSConfig{CAFile: os.DevNull}.Load()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestHTTPAuth(t *testing.T) {
+	Convey("Given a node requiring authentication", t, func() {
+		var auth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+		get := func() error {
+			hcfg, err := newHTTPConfig(cfg, DefaultTimeout)
+			if err != nil {
+				return err
+			}
+			resp, err := NewHTTPClientWithConfig(host, "", hcfg).Get("/")
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			return nil
+		}
+
+		Convey("basic authentication should send the user and password", func() {
+			cfg.AddItem(Username, ctypes.ConfigValueStr{Value: "cassandra"})
+			cfg.AddItem(Password, ctypes.ConfigValueStr{Value: "s3cret"})
+			So(get(), ShouldBeNil)
+			So(auth, ShouldEqual, "Basic Y2Fzc2FuZHJhOnMzY3JldA==")
+		})
+
+		Convey("the token should be read from the file", func() {
+			file, err := ioutil.TempFile("", "cassandra-token")
+			So(err, ShouldBeNil)
+			defer os.Remove(file.Name())
+			file.WriteString("t0ken\n")
+			file.Close()
+
+			cfg.AddItem(TokenFile, ctypes.ConfigValueStr{Value: file.Name()})
+			So(get(), ShouldBeNil)
+			So(auth, ShouldEqual, "Bearer t0ken")
+		})
+
+		Convey("the password should be read from the environment", func() {
+			os.Setenv("CASSANDRA_TEST_PASSWORD", "fr0m-env")
+			defer os.Unsetenv("CASSANDRA_TEST_PASSWORD")
+
+			cfg.AddItem(Username, ctypes.ConfigValueStr{Value: "cassandra"})
+			cfg.AddItem(PasswordEnv, ctypes.ConfigValueStr{Value: "CASSANDRA_TEST_PASSWORD"})
+			So(get(), ShouldBeNil)
+			So(auth, ShouldEqual, "Basic Y2Fzc2FuZHJhOmZyMG0tZW52")
+		})
+
+		Convey("a missing environment variable should be an error", func() {
+			cfg.AddItem(TokenEnv, ctypes.ConfigValueStr{Value: "CASSANDRA_TEST_MISSING"})
+			So(get(), ShouldNotBeNil)
+		})
+
+		Convey("basic and bearer authentication should not be combined", func() {
+			cfg.AddItem(Username, ctypes.ConfigValueStr{Value: "cassandra"})
+			cfg.AddItem(Token, ctypes.ConfigValueStr{Value: "t0ken"})
+			So(get(), ShouldNotBeNil)
+		})
+
+		Convey("a password should not be configured without a username", func() {
+			cfg.AddItem(Password, ctypes.ConfigValueStr{Value: "s3cret"})
+			So(get().Error(), ShouldEqual, PasswordWithoutUser)
+		})
+
+		Convey("the secrets of a cluster should be redacted from the log", func() {
+			cfg.AddItem(CassURL, ctypes.ConfigValueStr{Value: host})
+			cfg.AddItem(Token, ctypes.ConfigValueStr{Value: "l0gged-t0ken"})
+			cl, err := newCluster(cfg)
+			So(err, ShouldBeNil)
+			closed := false
+			defer func() {
+				if !closed {
+					cl.close()
+				}
+			}()
+
+			var buf bytes.Buffer
+			out := logger.Out
+			logger.Out = &buf
+			defer func() { logger.Out = out }()
+			logged := func(fields log.Fields, msg string) string {
+				buf.Reset()
+				cassLog.WithFields(fields).Error(msg)
+				return buf.String()
+			}
+
+			line := logged(log.Fields{"_block": "TestHTTPAuth", "token": "l0gged-t0ken"}, "request with l0gged-t0ken failed")
+			So(line, ShouldNotContainSubstring, "l0gged-t0ken")
+			So(line, ShouldContainSubstring, Redacted)
+
+			Convey("only where the secret is a whole token", func() {
+				line := logged(log.Fields{"node": "node-l0gged-t0ken"}, "node-l0gged-t0ken, l0gged-t0ken.")
+				So(line, ShouldContainSubstring, "node-l0gged-t0ken")
+				So(line, ShouldContainSubstring, Redacted+".")
+			})
+
+			Convey("and where it follows the key of a query parameter", func() {
+				line := logged(log.Fields{"url": "http://127.0.0.1:8778/jolokia/?token=l0gged-t0ken"}, "GET /jolokia/?token=l0gged-t0ken&x=1 failed")
+				So(line, ShouldNotContainSubstring, "l0gged-t0ken")
+				So(line, ShouldContainSubstring, "?token="+Redacted)
+			})
+
+			Convey("until the cluster is closed", func() {
+				cl.close()
+				closed = true
+				So(logged(log.Fields{}, "request with l0gged-t0ken failed"), ShouldContainSubstring, "l0gged-t0ken")
+			})
+
+			Convey("the credential fields should always be redacted", func() {
+				So(logged(log.Fields{"password": "an0ther"}, "failed"), ShouldNotContainSubstring, "an0ther")
+			})
+
+			Convey("the standard logger should be left alone", func() {
+				var std bytes.Buffer
+				out := log.StandardLogger().Out
+				log.SetOutput(&std)
+				defer log.SetOutput(out)
+				log.Error("request with l0gged-t0ken failed")
+				So(std.String(), ShouldContainSubstring, "l0gged-t0ken")
+			})
+		})
+	})
+}
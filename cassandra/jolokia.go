@@ -0,0 +1,415 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// JolokiaEndpoint the default path of the Jolokia agent
+	JolokiaEndpoint = "/jolokia/"
+	// MetricDomain the domain of the Cassandra metric MBeans
+	MetricDomain = "org.apache.cassandra.metrics"
+	// MetricPattern the ObjectName pattern of all Cassandra metric MBeans
+	MetricPattern = MetricDomain + ":*"
+
+	jolokiaSearch = "search"
+	jolokiaRead   = "read"
+	jolokiaList   = "list"
+
+	JolokiaRespErr = "Jolokia request failed"
+)
+
+// jolokiaRequest represents one request of a Jolokia bulk POST
+type jolokiaRequest struct {
+	Type   string                 `json:"type"`
+	MBean  string                 `json:"mbean,omitempty"`
+	Path   string                 `json:"path,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// jolokiaResponse represents one response of a Jolokia bulk POST
+type jolokiaResponse struct {
+	Status  int             `json:"status"`
+	Error   string          `json:"error"`
+	Request jolokiaRequest  `json:"request"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// jolokiaMBeanInfo represents the MBean meta data returned by a list request
+type jolokiaMBeanInfo struct {
+	Desc string                          `json:"desc"`
+	Attr map[string]jolokiaAttributeInfo `json:"attr"`
+}
+
+// jolokiaAttributeInfo represents the attribute meta data returned by a list request
+type jolokiaAttributeInfo struct {
+	Type string `json:"type"`
+	Desc string `json:"desc"`
+	RW   bool   `json:"rw"`
+}
+
+// newJolokiaRequest returns a request which keeps the ObjectName properties
+// in their registration order, so that Jolokia and MX4J build the same tree.
+func newJolokiaRequest(typ, mbean, path string) jolokiaRequest {
+	return jolokiaRequest{
+		Type:   typ,
+		MBean:  mbean,
+		Path:   path,
+		Config: map[string]interface{}{"canonicalNaming": false, "ignoreErrors": true},
+	}
+}
+
+// Jolokia reads the MBeans through the Jolokia agent
+type Jolokia struct {
+	client *HTTPClient
+
+	// types the declared types of the attributes by the canonical names of
+	// the MBeans, listed the first time an MBean is read with a map value
+	mutex sync.Mutex
+	types map[string]map[string]string
+}
+
+// NewJolokia returns a new instance of Jolokia
+func NewJolokia(client *HTTPClient) *Jolokia {
+	return &Jolokia{client: client, types: map[string]map[string]string{}}
+}
+
+// Close closes the connections to the agent
+func (j *Jolokia) Close() error {
+	j.client.Close()
+	return nil
+}
+
+// ListMBeans returns the object names matching the pattern
+func (j *Jolokia) ListMBeans(pattern string) ([]string, error) {
+	jresps, err := j.post([]jolokiaRequest{newJolokiaRequest(jolokiaSearch, pattern, "")})
+	if err != nil {
+		return nil, err
+	}
+	if len(jresps) != 1 || jresps[0].Status != http.StatusOK {
+		return nil, errors.New(JolokiaRespErr)
+	}
+
+	var names []string
+	err = json.Unmarshal(jresps[0].Value, &names)
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+	return names, nil
+}
+
+// ReadMBean returns the attributes of one MBean
+func (j *Jolokia) ReadMBean(objectname string) ([]Attribute, error) {
+	attrs, errs, err := j.ReadMBeans([]string{objectname})
+	if err != nil {
+		return nil, err
+	}
+	if err := errs[objectname]; err != nil {
+		return nil, err
+	}
+	attr, ok := attrs[objectname]
+	if !ok {
+		return nil, errMBeanNotFound(objectname)
+	}
+	return attr, nil
+}
+
+// ReadMBeans reads the attributes of all given MBeans with one bulk request.
+// The responses are matched with the object names by their canonical names,
+// so they're found whatever order the agent reports the key properties in.
+func (j *Jolokia) ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error) {
+	reqs := []jolokiaRequest{}
+	requested := map[string]string{}
+	for _, objectname := range objectnames {
+		reqs = append(reqs, newJolokiaRequest(jolokiaRead, objectname, ""))
+		requested[canonicalName(objectname)] = objectname
+	}
+
+	jresps, err := j.post(reqs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := map[string]map[string]interface{}{}
+	errs := map[string]error{}
+	for _, jresp := range jresps {
+		objectname, ok := requested[canonicalName(jresp.Request.MBean)]
+		if !ok {
+			objectname = jresp.Request.MBean
+		}
+		if jresp.Status != http.StatusOK {
+			cassLog.WithFields(log.Fields{
+				"_block": "ReadMBeans",
+				"mbean":  jresp.Request.MBean,
+				"error":  jresp.Error,
+			}).Warn(QueryDocErr)
+			errs[objectname] = jolokiaError(jresp, objectname)
+			continue
+		}
+
+		// the numbers are decoded as json.Number to keep the precision of longs
+		var value map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(jresp.Value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			errs[objectname] = errMalformed(err)
+			continue
+		}
+		values[objectname] = value
+	}
+
+	types := j.declaredTypes(values)
+	attrs := map[string][]Attribute{}
+	for objectname, value := range values {
+		attrs[objectname] = makeJolokiaAttributes(value, types[canonicalName(objectname)])
+	}
+	return attrs, errs, nil
+}
+
+// declaredTypes returns the declared types of the attributes of the MBeans
+// having a map value, by their canonical names. Jolokia renders composite and
+// tabular values as well as maps as objects, so only the declared types tell
+// them apart. The MBeans whose types aren't known yet are listed with one bulk
+// request, and the ones which can't be listed are listed again on their next read.
+func (j *Jolokia) declaredTypes(values map[string]map[string]interface{}) map[string]map[string]string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	types := map[string]map[string]string{}
+	reqs := []jolokiaRequest{}
+	listed := []string{}
+	for objectname, value := range values {
+		if !hasMapValue(value) {
+			continue
+		}
+		name := canonicalName(objectname)
+		if declared, ok := j.types[name]; ok {
+			types[name] = declared
+			continue
+		}
+		if req, err := newJolokiaListRequest(objectname); err == nil {
+			reqs = append(reqs, req)
+			listed = append(listed, name)
+		}
+	}
+	if len(reqs) == 0 {
+		return types
+	}
+
+	jresps, err := j.post(reqs)
+	if err != nil {
+		return types
+	}
+	// the responses of a bulk request come in the order of its requests
+	for i, jresp := range jresps {
+		if i >= len(listed) || jresp.Status != http.StatusOK {
+			continue
+		}
+		var info jolokiaMBeanInfo
+		if err := json.Unmarshal(jresp.Value, &info); err != nil {
+			continue
+		}
+		declared := map[string]string{}
+		for attr, ai := range info.Attr {
+			declared[attr] = ai.Type
+		}
+		j.types[listed[i]] = declared
+		types[listed[i]] = declared
+	}
+	return types
+}
+
+// hasMapValue returns true if any of the values is rendered as an object
+func hasMapValue(values map[string]interface{}) bool {
+	for _, value := range values {
+		if _, ok := value.(map[string]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newJolokiaListRequest returns the request listing the attributes of the MBean.
+// Jolokia lists the MBeans by their canonical key property lists.
+func newJolokiaListRequest(objectname string) (jolokiaRequest, error) {
+	on, err := ParseObjectName(objectname)
+	if err != nil {
+		return jolokiaRequest{}, err
+	}
+	path := escapeJolokiaPath(on.Domain) + Slash + escapeJolokiaPath(on.CanonicalPropertyList())
+	return newJolokiaRequest(jolokiaList, "", path), nil
+}
+
+// jolokiaError returns the error of a response which failed, an MBean not
+// found if the agent doesn't know the MBean
+func jolokiaError(jresp jolokiaResponse, objectname string) error {
+	if jresp.Status == http.StatusNotFound {
+		return errMBeanNotFound(objectname)
+	}
+	return fmt.Errorf("%s: %d %s", JolokiaRespErr, jresp.Status, jresp.Error)
+}
+
+// ListAttributes returns the declared attributes of the MBean
+// through a Jolokia list request.
+func (j *Jolokia) ListAttributes(objectname string) ([]Attribute, error) {
+	req, err := newJolokiaListRequest(objectname)
+	if err != nil {
+		return nil, err
+	}
+	jresps, err := j.post([]jolokiaRequest{req})
+	if err != nil {
+		return nil, err
+	}
+	if len(jresps) != 1 {
+		return nil, errors.New(JolokiaRespErr)
+	}
+	if jresps[0].Status != http.StatusOK {
+		return nil, jolokiaError(jresps[0], objectname)
+	}
+
+	var info jolokiaMBeanInfo
+	err = json.Unmarshal(jresps[0].Value, &info)
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+
+	attrs := []Attribute{}
+	for name, attr := range info.Attr {
+		attrs = append(attrs, Attribute{Name: name, Type: attr.Type})
+	}
+	return attrs, nil
+}
+
+// post sends the requests as one Jolokia bulk request
+func (j *Jolokia) post(reqs []jolokiaRequest) ([]jolokiaResponse, error) {
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.client.Post("application/json", bytes.NewReader(body))
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "post",
+			"error":  err,
+		}).Error(ReadDocErr)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", JolokiaRespErr, resp.Status)
+	}
+
+	var jresps []jolokiaResponse
+	err = json.NewDecoder(resp.Body).Decode(&jresps)
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+	return jresps, nil
+}
+
+// makeJolokiaAttributes converts the JSON attribute values into attributes.
+// Jolokia does not report the declared types along with the values, so
+// integers are marked as long, other numbers as double, strings as
+// java.lang.String and booleans as boolean. Lists and maps are rendered
+// the way Java renders them, and every other value is left out.
+// Jolokia renders composite and tabular values as objects too, so the entries
+// of an object are its items only if the attribute is declared in types as a
+// CompositeData or TabularData, as MX4J only parses the items of those.
+func makeJolokiaAttributes(values map[string]interface{}, types map[string]string) []Attribute {
+	attrs := []Attribute{}
+	for name, value := range values {
+		attr, ok := makeJolokiaAttribute(name, value)
+		if !ok {
+			continue
+		}
+		if v, isMap := value.(map[string]interface{}); isMap && (types[name] == CompositeDataType || types[name] == TabularDataType) {
+			attr.Type = types[name]
+			attr.Items = makeJolokiaItems(v)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// makeJolokiaItems converts the entries of a composite or tabular value into
+// its items. The objects nested in an open data value are open data as well.
+func makeJolokiaItems(values map[string]interface{}) []Attribute {
+	items := []Attribute{}
+	for name, value := range values {
+		item, ok := makeJolokiaAttribute(name, value)
+		if !ok {
+			continue
+		}
+		if v, isMap := value.(map[string]interface{}); isMap {
+			item.Type = CompositeDataType
+			item.Items = makeJolokiaItems(v)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// makeJolokiaAttribute converts one JSON value into an attribute,
+// it returns false if the value can't be converted
+func makeJolokiaAttribute(name string, value interface{}) (Attribute, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if n := parseNumber(v.String()); n != nil {
+			return Attribute{Name: name, Type: numberType(n), Value: n}, true
+		}
+	case float64:
+		return Attribute{Name: name, Type: "double", Value: v}, true
+	case string:
+		return Attribute{Name: name, Type: JavaStringType, Value: v}, true
+	case bool:
+		return Attribute{Name: name, Type: BooleanType, Value: v}, true
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return Attribute{Name: name, Type: "java.util.List", Text: "[" + strings.Join(items, ", ") + "]"}, true
+	case map[string]interface{}:
+		items := []string{}
+		for key, item := range v {
+			items = append(items, fmt.Sprintf("%s=%v", key, item))
+		}
+		return Attribute{Name: name, Type: "java.util.Map", Text: "{" + strings.Join(items, ", ") + "}"}, true
+	}
+	return Attribute{}, false
+}
+
+// escapeJolokiaPath escapes a path element of a Jolokia request
+func escapeJolokiaPath(s string) string {
+	s = strings.Replace(s, "!", "!!", -1)
+	return strings.Replace(s, Slash, "!"+Slash, -1)
+}
@@ -0,0 +1,191 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var jolokiaMBeans = map[string]map[string]interface{}{
+	"org.apache.cassandra.metrics:type=Table,keyspace=system,scope=peers,name=ReadLatency": {
+		"Count": 12, "Mean": 1.5, "LatencyUnit": "microseconds",
+	},
+	"org.apache.cassandra.metrics:type=Table,keyspace=system,scope=local,name=ReadLatency": {
+		"Count": 3, "Mean": 0.5, "LatencyUnit": "microseconds",
+	},
+}
+
+func newJolokiaServer(posts *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*posts++
+		var reqs []jolokiaRequest
+		json.NewDecoder(r.Body).Decode(&reqs)
+
+		resps := []map[string]interface{}{}
+		for _, req := range reqs {
+			resp := map[string]interface{}{"status": 200, "request": req}
+			switch req.Type {
+			case jolokiaSearch:
+				names := []string{}
+				for name := range jolokiaMBeans {
+					names = append(names, name)
+				}
+				resp["value"] = names
+			case jolokiaRead:
+				values, ok := jolokiaMBeans[req.MBean]
+				if !ok {
+					resp["status"] = 404
+				}
+				resp["value"] = values
+			case jolokiaList:
+				resp["value"] = map[string]interface{}{
+					"attr": map[string]interface{}{
+						"Count":       map[string]interface{}{"type": "long"},
+						"LatencyUnit": map[string]interface{}{"type": JavaStringType},
+					},
+				}
+			}
+			resps = append(resps, resp)
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+}
+
+func TestJolokiaTransport(t *testing.T) {
+	Convey("Given a Jolokia agent", t, func() {
+		posts := 0
+		server := newJolokiaServer(&posts)
+		defer server.Close()
+
+		transport, _ := newTransport(JolokiaTransport, strings.TrimPrefix(server.URL, "http://"), HTTPConfig{Timeout: DefaultTimeout}, DefaultConcurrency)
+		cc := NewCassClient("node1", transport)
+
+		Convey("ListMBeans should return the searched object names", func() {
+			mbeans, err := transport.ListMBeans(MetricPattern)
+			So(err, ShouldBeNil)
+			So(mbeans, ShouldHaveLength, 2)
+		})
+
+		Convey("getElementTypes should list numeric and string attributes", func() {
+			mts, err := cc.getElementTypes("org.apache.cassandra.metrics:type=Table,keyspace=system,scope=peers,name=ReadLatency")
+			So(err, ShouldBeNil)
+			So(mts, ShouldHaveLength, 2)
+			units := map[string]string{}
+			for _, mt := range mts {
+				units[mt.Namespace().Strings()[len(mt.Namespace().Strings())-1]] = mt.Unit()
+			}
+			// the catalog tells the units from the dynamic name, not from ReadLatency
+			So(units, ShouldContainKey, "Count")
+			So(units["Count"], ShouldEqual, "")
+			So(units["LatencyUnit"], ShouldEqual, "")
+		})
+
+		Convey("ReadMBeans should return the error of an MBean the agent doesn't know", func() {
+			missing := "org.apache.cassandra.metrics:type=Table,keyspace=system,scope=missing,name=ReadLatency"
+			attrs, errs, err := transport.ReadMBeans([]string{missing, "org.apache.cassandra.metrics:type=Table,keyspace=system,scope=local,name=ReadLatency"})
+			So(err, ShouldBeNil)
+			So(attrs, ShouldHaveLength, 1)
+			So(errs, ShouldContainKey, missing)
+			So(newCollectError(errs[missing], missing).Kind, ShouldEqual, MBeanNotFound)
+		})
+
+		Convey("collect should read a wildcard with one bulk request", func() {
+			mbeans, _ := transport.ListMBeans(MetricPattern)
+			for _, mbean := range mbeans {
+				cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+			}
+			posts = 0
+
+			results := []nodeData{}
+			search := strings.Split("org.apache.cassandra.metrics/type/Table/keyspace/system/scope/*/name/ReadLatency/Count", "/")
+			err := cc.collect(cc.newCycle(), search, &results)
+			So(err, ShouldBeNil)
+			So(posts, ShouldEqual, 1)
+			So(results, ShouldHaveLength, 2)
+			for _, result := range results {
+				So(result.Path, ShouldStartWith, "org.apache.cassandra.metrics/type/Table/keyspace/system/scope/")
+				So(result.Path, ShouldEndWith, "/name/ReadLatency/Count")
+			}
+		})
+	})
+}
+
+func TestJolokiaOpenData(t *testing.T) {
+	Convey("Given a Jolokia agent reporting a composite value and a map", t, func() {
+		mbean := "org.apache.cassandra.db:type=StorageService"
+		lists := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var reqs []jolokiaRequest
+			json.NewDecoder(r.Body).Decode(&reqs)
+
+			resps := []map[string]interface{}{}
+			for _, req := range reqs {
+				resp := map[string]interface{}{"status": 200, "request": req}
+				switch req.Type {
+				case jolokiaRead:
+					resp["value"] = map[string]interface{}{
+						"Snapshot": map[string]interface{}{"Median": 1.5, "Max": 3},
+						"LoadMap":  map[string]interface{}{"127.0.0.1": "1.2 MB"},
+					}
+				case jolokiaList:
+					lists++
+					resp["value"] = map[string]interface{}{
+						"attr": map[string]interface{}{
+							"Snapshot": map[string]interface{}{"type": CompositeDataType},
+							"LoadMap":  map[string]interface{}{"type": "java.util.Map"},
+						},
+					}
+				}
+				resps = append(resps, resp)
+			}
+			json.NewEncoder(w).Encode(resps)
+		}))
+		defer server.Close()
+
+		transport, _ := newTransport(JolokiaTransport, strings.TrimPrefix(server.URL, "http://"), HTTPConfig{Timeout: DefaultTimeout}, DefaultConcurrency)
+
+		Convey("only the items of the composite value should be flattened", func() {
+			attrs, err := transport.ReadMBean(mbean)
+			So(err, ShouldBeNil)
+			names := []string{}
+			for _, leaf := range flattenAttributes(attrs) {
+				names = append(names, leaf.Name)
+			}
+			So(names, ShouldHaveLength, 3)
+			So(names, ShouldContain, "Snapshot/Median")
+			So(names, ShouldContain, "Snapshot/Max")
+			So(names, ShouldContain, "LoadMap")
+		})
+
+		Convey("the declared types should be listed once", func() {
+			transport.ReadMBean(mbean)
+			transport.ReadMBean(mbean)
+			So(lists, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,174 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"github.com/intelsdi-x/snap/control/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// JVMDomain the domain of the JVM platform MBeans
+	JVMDomain = "java.lang"
+	// JVMPattern the ObjectName pattern of the JVM platform MBeans
+	JVMPattern = JVMDomain + ":*"
+
+	NoJVMMBeansErr = "JVM MBeans could not be listed"
+)
+
+// jvmTypes are the types of the JVM platform MBeans which are collected
+var jvmTypes = map[string]bool{
+	"Memory":           true,
+	"MemoryPool":       true,
+	"GarbageCollector": true,
+	"Threading":        true,
+	"Runtime":          true,
+	"OperatingSystem":  true,
+	"ClassLoading":     true,
+}
+
+// jvmCatalog defines the JVM metrics listed by GetMetricTypes without
+// reading them from a node. The names of the garbage collectors and the
+// memory pools depend on the JVM, so they are dynamic elements.
+var jvmCatalog = []struct {
+	objectname string
+	attrs      map[string]string
+}{
+	{"java.lang:type=Memory", withMemoryUsage(map[string]string{
+		"ObjectPendingFinalizationCount": "int",
+	}, "HeapMemoryUsage", "NonHeapMemoryUsage")},
+	{"java.lang:type=MemoryPool,name=*", withMemoryUsage(map[string]string{
+		"UsageThresholdCount":           "long",
+		"CollectionUsageThresholdCount": "long",
+	}, "Usage", "PeakUsage", "CollectionUsage")},
+	{"java.lang:type=GarbageCollector,name=*", map[string]string{
+		"CollectionCount":          "long",
+		"CollectionTime":           "long",
+		"LastGcInfo/GcThreadCount": "int",
+		"LastGcInfo/duration":      "long",
+		"LastGcInfo/startTime":     "long",
+		"LastGcInfo/endTime":       "long",
+		"LastGcInfo/id":            "long",
+	}},
+	{"java.lang:type=Threading", map[string]string{
+		"ThreadCount":             "int",
+		"PeakThreadCount":         "int",
+		"DaemonThreadCount":       "int",
+		"TotalStartedThreadCount": "long",
+	}},
+	{"java.lang:type=Runtime", map[string]string{
+		"Uptime":    "long",
+		"StartTime": "long",
+	}},
+	{"java.lang:type=OperatingSystem", map[string]string{
+		"AvailableProcessors":     "int",
+		"SystemLoadAverage":       "double",
+		"ProcessCpuLoad":          "double",
+		"SystemCpuLoad":           "double",
+		"ProcessCpuTime":          "long",
+		"OpenFileDescriptorCount": "long",
+		"MaxFileDescriptorCount":  "long",
+		"FreePhysicalMemorySize":  "long",
+		"TotalPhysicalMemorySize": "long",
+	}},
+	{"java.lang:type=ClassLoading", map[string]string{
+		"LoadedClassCount":      "int",
+		"TotalLoadedClassCount": "long",
+		"UnloadedClassCount":    "long",
+	}},
+}
+
+// withMemoryUsage adds the items of the MemoryUsage attributes to the attributes
+func withMemoryUsage(attrs map[string]string, names ...string) map[string]string {
+	for _, name := range names {
+		for _, item := range []string{"init", "used", "committed", "max"} {
+			attrs[name+Slash+item] = "long"
+		}
+	}
+	return attrs
+}
+
+// isJVMMBean returns true if the MBean is one of the collected JVM platform MBeans
+func isJVMMBean(objectname string) bool {
+	on, err := ParseObjectName(objectname)
+	if err != nil || on.Domain != JVMDomain {
+		return false
+	}
+	return jvmTypes[on.Get("type")]
+}
+
+// listJVMMBeans returns the collected JVM platform MBeans of the node
+func listJVMMBeans(t Transport) ([]string, error) {
+	mbeans, err := t.ListMBeans(JVMPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, mbean := range mbeans {
+		if isJVMMBean(mbean) {
+			names = append(names, mbean)
+		}
+	}
+	return names, nil
+}
+
+// jvmMetricTypes returns the metric types of the JVM catalog
+func jvmMetricTypes() []plugin.MetricType {
+	mts := []plugin.MetricType{}
+	for _, mbean := range jvmCatalog {
+		for name := range mbean.attrs {
+			mts = append(mts, describeMetricType(plugin.MetricType{
+				Namespace_: makeDynamicNamespace("", mbean.objectname, name),
+			}))
+		}
+	}
+	return mts
+}
+
+// addJVMTargets adds the JVM platform MBeans of the node into the tree.
+// Their names depend on the JVM of the node, so they're listed the first
+// time a JVM metric is collected rather than shipped with the tree.
+func (cc *CassClient) addJVMTargets(c *cycle) {
+	cc.mutex.RLock()
+	listed := cc.jvmListed
+	cc.mutex.RUnlock()
+	if listed || c.check() != nil {
+		return
+	}
+
+	mbeans, err := listJVMMBeans(cc.transport)
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "addJVMTargets",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(NoJVMMBeansErr)
+		c.failed(err)
+		return
+	}
+
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	for _, mbean := range mbeans {
+		cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+	}
+	cc.jvmListed = true
+}
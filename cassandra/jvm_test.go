@@ -0,0 +1,92 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJVMMetrics(t *testing.T) {
+	Convey("Given a node exposing the JVM platform MBeans", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans["java.lang:type=GarbageCollector,name=ParNew"] = []Attribute{
+			{Name: "CollectionCount", Type: "long", Value: int64(7)},
+			{Name: "CollectionTime", Type: "long", Value: int64(120)},
+		}
+		transport.mbeans["java.lang:type=GarbageCollector,name=ConcurrentMarkSweep"] = []Attribute{
+			{Name: "CollectionCount", Type: "long", Value: int64(1)},
+			{Name: "CollectionTime", Type: "long", Value: int64(40)},
+		}
+		transport.mbeans["java.lang:type=Threading"] = []Attribute{
+			{Name: "ThreadCount", Type: "int", Value: int64(42)},
+		}
+		transport.mbeans["java.lang:type=Compilation"] = []Attribute{
+			{Name: "TotalCompilationTime", Type: "long", Value: int64(1000)},
+		}
+
+		cc := NewCassClient("node1", transport)
+		for mbean := range transport.mbeans {
+			if !strings.HasPrefix(mbean, JVMDomain) {
+				cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+			}
+		}
+
+		Convey("only the collected JVM types should be listed", func() {
+			mbeans, err := listJVMMBeans(transport)
+			So(err, ShouldBeNil)
+			So(mbeans, ShouldHaveLength, 3)
+			So(isJVMMBean("java.lang:type=Compilation"), ShouldBeFalse)
+			So(isJVMMBean("org.apache.cassandra.metrics:type=Memory"), ShouldBeFalse)
+		})
+
+		Convey("the JVM metrics should be collected under their own subtree", func() {
+			mts := []plugin.MetricType{
+				{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "java_lang",
+					"type", "GarbageCollector", "name", "*", "CollectionCount")},
+				{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "java_lang",
+					"type", "Threading", "ThreadCount")},
+			}
+			metrics, errs := cc.collectMetrics(mts)
+			So(errs, ShouldBeEmpty)
+			So(metrics, ShouldHaveLength, 3)
+			for _, m := range metrics {
+				So(m.Namespace().Strings()[4], ShouldEqual, JVMDomain)
+			}
+			So(cc.jvmListed, ShouldBeTrue)
+		})
+
+		Convey("the catalog should list the JVM metrics", func() {
+			found := map[string]bool{}
+			for _, mt := range jvmMetricTypes() {
+				found[strings.Join(mt.Namespace().Strings(), "/")] = true
+			}
+			So(found["intel/cassandra/node/*/java_lang/type/*/name/*/CollectionTime"], ShouldBeTrue)
+			So(found["intel/cassandra/node/*/java_lang/type/*/Uptime"], ShouldBeTrue)
+			So(found["intel/cassandra/node/*/java_lang/type/*/LoadedClassCount"], ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,233 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	ServerQuery    = "/serverbydomain?querynames="
+	MbeanQuery     = "/mbean?objectname="
+	QuerySuffix    = "&template=identity"
+	MetricQuery    = ServerQuery + MetricPattern + QuerySuffix
+	JavaStringType = "java.lang.String"
+)
+
+// XMLServer represents Server element
+type XMLServer struct {
+	XMLName xml.Name  `xml:"Server"`
+	Domain  XMLDomain `xml:"Domain"`
+}
+
+// XMLDomain represents Domain element
+type XMLDomain struct {
+	XMLName xml.Name   `xml:"Domain"`
+	MBeans  []XMLMBean `xml:"MBean"`
+}
+
+// XMLMBean represents MBean element
+type XMLMBean struct {
+	XMLName    xml.Name `xml:"MBean"`
+	Objectname string   `xml:"objectname,attr"`
+}
+
+//XMLAttributes represents list of Attribute elements
+type XMLAttributes struct {
+	XMLName    xml.Name       `xml:"MBean"`
+	Attributes []XMLAttribute `xml:"Attribute"`
+}
+
+// XMLAttribute represents Attribute element
+type XMLAttribute struct {
+	XMLName xml.Name `xml:"Attribute"`
+	Name    string   `xml:"name,attr"`
+	Type    string   `xml:"type,attr"`
+	Value   string   `xml:"value,attr"`
+}
+
+// MX4J reads the MBeans through the MX4J HTTP adaptor
+type MX4J struct {
+	client *HTTPClient
+	// concurrency the maximum number of MBeans read at the same time
+	concurrency int
+}
+
+// NewMX4J returns a new instance of MX4J
+func NewMX4J(client *HTTPClient) *MX4J {
+	return &MX4J{client: client, concurrency: DefaultConcurrency}
+}
+
+// Close closes the connections to the node
+func (m *MX4J) Close() error {
+	m.client.Close()
+	return nil
+}
+
+// ListMBeans returns the object names matching the pattern
+func (m *MX4J) ListMBeans(pattern string) ([]string, error) {
+	resp, err := m.client.Get(ServerQuery + url.QueryEscape(pattern) + QuerySuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mbeans, err := readObjectname(resp.Body)
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+
+	names := []string{}
+	for _, mbean := range mbeans {
+		names = append(names, mbean.Objectname)
+	}
+	return names, nil
+}
+
+// ReadMBean returns the attributes of one MBean
+func (m *MX4J) ReadMBean(objectname string) ([]Attribute, error) {
+	resp, err := m.client.Get(MbeanQuery + url.QueryEscape(objectname) + QuerySuffix)
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "ReadMBean",
+			"error":  err,
+		}).Error(ReadDocErr)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errMBeanNotFound(objectname)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if string(contents) == EmptyRespErr {
+		cassLog.WithFields(log.Fields{
+			"_block": "ReadMBean",
+			"mbean":  objectname,
+		}).Error(QueryDocErr)
+		return nil, errMBeanNotFound(objectname)
+	}
+
+	xmlAttrs, err := readXMLAttrbutes(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []Attribute{}
+	for _, attr := range xmlAttrs {
+		if items, ok := parseOpenData(attr.Value); ok {
+			attrs = append(attrs, Attribute{Name: attr.Name, Type: attr.Type, Text: attr.Value, Items: items})
+			continue
+		}
+		attrs = append(attrs, newAttribute(attr.Name, attr.Type, attr.Value))
+	}
+	return attrs, nil
+}
+
+// ReadMBeans returns the attributes of the given MBeans. MX4J has no
+// bulk request, so they are read one request per MBean by a pool of at
+// most concurrency workers. A network error fails the whole read, as the
+// node is unreachable, and the MBeans not read yet are given up.
+func (m *MX4J) ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error) {
+	workers := m.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(objectnames) {
+		workers = len(objectnames)
+	}
+
+	queue := make(chan string)
+	attrs := map[string][]Attribute{}
+	errs := map[string]error{}
+	var netErr error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for objectname := range queue {
+				attr, err := m.ReadMBean(objectname)
+
+				mutex.Lock()
+				if _, ok := err.(net.Error); ok && netErr == nil {
+					netErr = err
+				}
+				if err == nil {
+					attrs[objectname] = attr
+				} else {
+					errs[objectname] = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, objectname := range objectnames {
+		mutex.Lock()
+		failed := netErr != nil
+		mutex.Unlock()
+		if failed {
+			break
+		}
+		queue <- objectname
+	}
+	close(queue)
+	wg.Wait()
+
+	if netErr != nil {
+		return nil, nil, netErr
+	}
+	return attrs, errs, nil
+}
+
+func readObjectname(reader io.Reader) ([]XMLMBean, error) {
+	var xmlServer XMLServer
+	err := xml.NewDecoder(reader).Decode(&xmlServer)
+	if err != nil {
+		return nil, err
+	}
+	return xmlServer.Domain.MBeans, nil
+}
+
+func readXMLAttrbutes(content []byte) ([]XMLAttribute, error) {
+	var xmlAttributes XMLAttributes
+	err := xml.Unmarshal(content, &xmlAttributes)
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+	return xmlAttributes.Attributes, nil
+}
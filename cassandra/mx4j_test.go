@@ -0,0 +1,153 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newMX4JServer serves MBeans of a Count attribute, each read taking a while,
+// and records the highest number of reads served at the same time
+func newMX4JServer(mbeans []string, peak *int) *httptest.Server {
+	var mutex sync.Mutex
+	inflight := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/serverbydomain" {
+			fmt.Fprint(w, `<Server><Domain name="org.apache.cassandra.metrics">`)
+			for _, mbean := range mbeans {
+				fmt.Fprintf(w, `<MBean objectname="%s"/>`, mbean)
+			}
+			fmt.Fprint(w, `</Domain></Server>`)
+			return
+		}
+
+		mutex.Lock()
+		inflight++
+		if inflight > *peak {
+			*peak = inflight
+		}
+		mutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		inflight--
+		mutex.Unlock()
+
+		objectname := r.URL.Query().Get("objectname")
+		if objectname == StorageServiceMBean {
+			fmt.Fprintf(w, `<MBean objectname="%s"><Attribute name="ReleaseVersion" type="java.lang.String" value="4.0.11"/></MBean>`, objectname)
+			return
+		}
+		fmt.Fprintf(w, `<MBean objectname="%s"><Attribute name="Count" type="long" value="1"/></MBean>`, objectname)
+	}))
+}
+
+func TestMX4JConcurrency(t *testing.T) {
+	Convey("Given a node read through MX4J", t, func() {
+		mbeans := []string{}
+		for i := 0; i < 12; i++ {
+			mbeans = append(mbeans, fmt.Sprintf("org.apache.cassandra.metrics:type=Table,keyspace=ks,scope=t%d,name=ReadLatency", i))
+		}
+		peak := 0
+		server := newMX4JServer(mbeans, &peak)
+		defer server.Close()
+
+		transport, _ := newTransport(MX4JTransport, strings.TrimPrefix(server.URL, "http://"), HTTPConfig{Timeout: DefaultTimeout}, 4)
+
+		Convey("the MBeans should be read by a bounded pool of workers", func() {
+			attrs, errs, err := transport.ReadMBeans(mbeans)
+			So(err, ShouldBeNil)
+			So(errs, ShouldBeEmpty)
+			So(attrs, ShouldHaveLength, len(mbeans))
+			So(peak, ShouldBeGreaterThan, 1)
+			So(peak, ShouldBeLessThanOrEqualTo, 4)
+		})
+
+		Convey("the MBeans of all requested namespaces should be read concurrently", func() {
+			cc := NewCassClient("node1", transport)
+			for _, mbean := range mbeans {
+				cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+			}
+			mts := []plugin.MetricType{}
+			for i := range mbeans {
+				mts = append(mts, plugin.MetricType{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*",
+					"org_apache_cassandra_metrics", "type", "Table", "keyspace", "ks", "scope", fmt.Sprintf("t%d", i), "name", "ReadLatency", "Count")})
+			}
+			metrics, errs := cc.collectMetrics(mts)
+			So(errs, ShouldBeEmpty)
+			So(metrics, ShouldHaveLength, len(mbeans))
+			So(peak, ShouldBeGreaterThan, 1)
+			So(peak, ShouldBeLessThanOrEqualTo, 4)
+		})
+	})
+}
+
+func TestMX4JReadErrors(t *testing.T) {
+	Convey("Given a node returning a malformed document for one MBean", t, func() {
+		good := "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits"
+		bad := "org.apache.cassandra.metrics:type=Cache,scope=RowCache,name=Hits"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("objectname") == bad {
+				fmt.Fprint(w, `<MBean objectname="`)
+				return
+			}
+			objectname := r.URL.Query().Get("objectname")
+		if objectname == StorageServiceMBean {
+			fmt.Fprintf(w, `<MBean objectname="%s"><Attribute name="ReleaseVersion" type="java.lang.String" value="4.0.11"/></MBean>`, objectname)
+			return
+		}
+		fmt.Fprintf(w, `<MBean objectname="%s"><Attribute name="Count" type="long" value="1"/></MBean>`, objectname)
+		}))
+		defer server.Close()
+
+		transport, _ := newTransport(MX4JTransport, strings.TrimPrefix(server.URL, "http://"), HTTPConfig{Timeout: DefaultTimeout}, 2)
+
+		Convey("the error of the MBean should be returned along with the MBeans which were read", func() {
+			attrs, errs, err := transport.ReadMBeans([]string{good, bad})
+			So(err, ShouldBeNil)
+			So(attrs, ShouldContainKey, good)
+			So(errs, ShouldContainKey, bad)
+			So(newCollectError(errs[bad], bad).Kind, ShouldEqual, MalformedResponse)
+		})
+
+		Convey("the MBean should fail the same whether it's read alone or through a wildcard", func() {
+			cc := NewCassClient("node1", transport)
+			cc.Root.Add(makeLitteralNamespace(good, ""), 0, good)
+			cc.Root.Add(makeLitteralNamespace(bad, ""), 0, bad)
+			for _, scope := range []string{"*", "RowCache"} {
+				_, errs := cc.collectMetrics([]plugin.MetricType{{Namespace_: core.NewNamespace("intel", "cassandra", "node", "*",
+					"org_apache_cassandra_metrics", "type", "Cache", "scope", scope, "name", "Hits", "Count")}})
+				So(errs, ShouldHaveLength, 1)
+				So(errs[0].Kind, ShouldEqual, MalformedResponse)
+			}
+		})
+	})
+}
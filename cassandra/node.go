@@ -19,10 +19,10 @@ limitations under the License.
 package cassandra
 
 import (
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -41,21 +41,38 @@ type node struct {
 	Children map[string]*node
 	Target   *nodeTarget
 	Data     *nodeData
+	// Composite the node holds the items of a composite or tabular attribute
+	Composite bool `json:"-"`
 }
 
 // nodeTarget defines the callable host and the endpoint.
-// Only leaf nodes have this property.
+// Only leaf nodes have this property. The mutex guards the target's
+// fields and the attribute children of its node, so concurrent
+// collections only wait for each other on the MBeans they share.
 type nodeTarget struct {
+	mutex sync.Mutex
 	// URI the target URI such as  org.apache.cassandra.metrics&type=CQL,name=PreparedStatementsCount
-	URI    string
-	Loaded bool
+	URI string
+	// Expires the time until the attributes may be served without reading them again
+	Expires time.Time `json:"-"`
+	// Err the error of the last read of the attributes
+	Err error `json:"-"`
 }
 
 // nodeData defines the key and value pair of the node data.
 // Only leaf nodes have this property.
 type nodeData struct {
 	Path string
-	Data interface{}
+	// MBean the object name of the MBean the data point was read from
+	MBean string
+	// Attr the path of the attribute within the MBean
+	Attr        string
+	Data        interface{}
+	Unit        string
+	Description string
+	// Aliases the ObjectName values the data point was requested by under
+	// another alias, by their keys
+	Aliases map[string]string `json:"-"`
 }
 
 // newNode returns a new instance with the node name
@@ -75,10 +92,20 @@ func newNodeData(path string, data interface{}) *nodeData {
 	return &nodeData{Path: path, Data: data}
 }
 
+// elements returns the namespace elements of the data point, the ones of
+// its MBean followed by the attribute path. Unlike the elements of the
+// path the ObjectName values may hold slashes.
+func (d nodeData) elements() []string {
+	return append(d.mbeanElements(), strings.Split(d.Attr, Slash)...)
+}
+
 // Add adds a path into the tree. Each entry in names is a part of a path between two slashes.
+// A target already in the tree is kept along with its attributes.
 func (n *node) Add(names []string, index int, uri string) {
 	if index == len(names) {
-		n.Target = newNodeTarget(uri)
+		if n.Target == nil || n.Target.URI != uri {
+			n.Target = newNodeTarget(uri)
+		}
 		return
 	}
 
@@ -94,7 +121,12 @@ func (n *node) Add(names []string, index int, uri string) {
 // Get returns results that match the specified path which may contain wildcards and |'s which serve as OR booleans.
 // For example /a/b/*/d will return all nodes under "b" which themselves have a child "d".
 // Another example is /a/b/c|d/e which returns /a/b/c/e and /a/b/d/e.
-func (n *node) Get(url string, names []string, index int, results *[]nodeData) (err error) {
+// A name the node has no child of is resolved through its aliases.
+// The traversal goes on past the targets which could not be read, so the results hold
+// everything which could be read and the first error is returned.
+// In tag mode the keyspaces and tables are matched even if the path leaves them out.
+// The target of the node is locked while the path is traversed below it.
+func (n *node) Get(c *cycle, names []string, index int, results *[]nodeData) (err error) {
 	// we've reached the end of the path, so add to the results if this node has anything to add.
 	if index == len(names) {
 		if n.Data != nil {
@@ -103,105 +135,194 @@ func (n *node) Get(url string, names []string, index int, results *[]nodeData) (
 		return
 	}
 
+	if n.Target != nil {
+		n.Target.mutex.Lock()
+		defer n.Target.mutex.Unlock()
+		// load the attributes if it's an end node of a callable target
+		err = n.loadElements(c)
+	}
+
 	// Go through each substring if a pipe exists inside a string
-	tokens := strings.Split(names[index], Pipe)
-	if len(tokens) > 1 {
-		for _, token := range tokens {
-			err = n.getSpecific(url, token, names, index, results)
+	for _, token := range strings.Split(names[index], Pipe) {
+		if e := n.getSpecific(c, token, names, index, results); err == nil {
+			err = e
 		}
-	} else {
-		err = n.getSpecific(url, names[index], names, index, results)
 	}
-	return nil
+	for _, child := range n.tagChildren(c, names[index]) {
+		if e := child.Get(c, names, index, results); err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 // getSpecific traverses through the node and finds the matching data set.
-// If requested, the XML will be loaded into child nodes as it is needed. Once loaded it serves as a cache so the same url
-// won't be reloaded over and over if multiple values are required from the same page.
+// If requested, the attributes will be loaded into child nodes as they are needed. Once loaded they serve
+// as a cache for the rest of the cycle so the same MBean won't be reloaded over and over if multiple values
+// are required from it.
 // The results will be empty if no matches are found.
-func (n *node) getSpecific(url, name string, names []string, index int, results *[]nodeData) (err error) {
-	if len(n.Children) == 0 && n.Target != nil {
-		// load XML if we're in a leaf node and there is a url to load from.
-		err = n.loadElements(url)
-	} else if n.Target != nil {
-		// load XML if it's an end node of a callable target
-		// and the searching name does not exist in its children
-		_, ok := n.Children[name]
-		if !ok {
-			err = n.loadElements(url)
-		}
-	}
-
+func (n *node) getSpecific(c *cycle, name string, names []string, index int, results *[]nodeData) (err error) {
 	if name == Wildcard {
 		// traverse all children to find matches if it is *
 		for _, child := range n.Children {
-			err = child.Get(url, names, index+1, results)
+			if e := child.Get(c, names, index+1, results); err == nil {
+				err = e
+			}
+		}
+	} else if child, alias := n.child(c, name, names, index); child != nil {
+		start := len(*results)
+		if e := child.Get(c, names, index+1, results); err == nil {
+			err = e
 		}
-	} else {
-		child, ok := n.Children[name]
-		if ok {
-			err = child.Get(url, names, index+1, results)
+		if alias != name {
+			withAlias((*results)[start:], n.Name, name)
 		}
 	}
-	return nil
+	return err
 }
 
-// addXMLAttibutes adds XML attributes into the tree
-func (n *node) addXMLAttibutes(ns string, attrs []XMLAttribute) {
-	for _, attr := range attrs {
-		if attr.Type != JavaStringType {
+// addXMLAttibutes adds the attributes into the tree. The values of the attributes
+// already in the tree are replaced and the attributes no longer reported are removed.
+// The items of composite and tabular attributes are added as their children.
+// Numeric, string and boolean attributes are added, lists and maps are not.
+// The units are told from the ObjectName and the other attributes of the MBean.
+func (n *node) addXMLAttibutes(ns, objectname string, attrs []Attribute) {
+	leaves := flattenAttributes(attrs)
+	siblings := map[string]interface{}{}
+	for _, attr := range leaves {
+		siblings[attr.Name] = attr.Value
+	}
+	domain, props := objectNameProps(objectname)
+
+	read := map[string]bool{}
+	for _, attr := range leaves {
+		if attr.Value != nil {
+			read[attr.Name] = true
 
-			nc, ok := n.Children[attr.Name]
-			if !ok {
-				nc = newNode(attr.Name)
-				nc.Data = newNodeData(ns+Slash+attr.Name, attr.Value)
-				n.Children[attr.Name] = nc
+			nc := n
+			for _, name := range strings.Split(attr.Name, Slash) {
+				c, ok := nc.Children[name]
+				if !ok {
+					c = newNode(name)
+					nc.Children[name] = c
+				}
+				if nc != n {
+					nc.Composite = true
+				}
+				nc = c
 			}
+			nc.Data = newNodeData(ns+Slash+attr.Name, attr.Value)
+			nc.Data.MBean = objectname
+			nc.Data.Attr = attr.Name
+			nc.Data.Unit = metricUnit(domain, props, attr.Name, siblings)
+			nc.Data.Description = metricDescription(attr.Name)
+		}
+	}
+	n.removeAttributes(read, "")
+}
+
+// removeAttributes removes the attribute children not in keep, which
+// holds the attribute paths relative to the target.
+func (n *node) removeAttributes(keep map[string]bool, prefix string) {
+	for name, child := range n.Children {
+		if child.Composite {
+			child.removeAttributes(keep, prefix+name+Slash)
+			if len(child.Children) == 0 {
+				delete(n.Children, name)
+			}
+			continue
+		}
+		if child.Data != nil && len(child.Children) == 0 && !keep[prefix+name] {
+			delete(n.Children, name)
 		}
 	}
 }
 
-// loadElements loads the XML hasn't been loaded into the tree yet, load it and add it to the tree.
-func (n *node) loadElements(url string) error {
-	if n.Target.Loaded {
-		return nil
+// loadElements reads the target's attributes into the tree unless they were read in this cycle
+// or are still cached. If the read fails the values of the previous cycle are removed, so stale
+// values are never returned, and the error is returned for every path through the target.
+func (n *node) loadElements(c *cycle) error {
+	if c.fresh(n.Target) {
+		return n.Target.Err
 	}
-	resp, err := getResp(url, n.Target.URI)
+	if err := c.check(); err != nil {
+		n.clearAttributes(c, err)
+		return n.Target.Err
+	}
+	resp, err := c.transport.ReadMBean(n.Target.URI)
 	if err != nil {
 		cassLog.WithFields(log.Fields{
 			"_block": "loadElements",
 			"error":  err,
 		}).Error(ReadDocErr)
-		return err
+		c.failed(err)
+		n.clearAttributes(c, err)
+		return n.Target.Err
 	}
-	ns := makeLitteralNamespace(n.Target.URI, "")
-	n.addXMLAttibutes(strings.Join(ns, "/"), resp)
-	n.Target.Loaded = true
+	n.setAttributes(c, resp)
 	return nil
 }
 
-func getResp(url, uri string) ([]XMLAttribute, error) {
-	client := NewHTTPClient(url, "", DefaultTimeout)
-	resp, err := client.httpClient.Get(url + MbeanQuery + uri + QuerySuffix)
+// setAttributes adds the attributes read from the target into the tree
+// and marks the target as loaded in the cycle.
+func (n *node) setAttributes(c *cycle, attrs []Attribute) {
+	if c.states {
+		attrs = withStates(attrs)
+	}
+	ns := makeLitteralNamespace(n.Target.URI, "")
+	n.addXMLAttibutes(strings.Join(ns, "/"), n.Target.URI, attrs)
+	c.loaded(n.Target)
+	n.Target.Err = nil
+}
+
+// storeRead adds the attributes read from the target into the tree, or
+// removes them if the read failed, with the target locked
+func (n *node) storeRead(c *cycle, attrs []Attribute, err error) {
+	n.Target.mutex.Lock()
+	defer n.Target.mutex.Unlock()
 	if err != nil {
-		cassLog.WithFields(log.Fields{
-			"_block": "getResp",
-			"error":  err,
-		}).Error(ReadDocErr)
-		return nil, err
+		n.clearAttributes(c, err)
+		return
 	}
-	defer resp.Body.Close()
+	n.setAttributes(c, attrs)
+}
 
-	contents, err := ioutil.ReadAll(resp.Body)
-	if err != nil || string(contents) == EmptyRespErr {
-		cassLog.WithFields(log.Fields{
-			"_block": "getResp",
-			"error":  err,
-		}).Error(QueryDocErr)
-		return nil, errors.New(QueryDocErr)
+// clearAttributes removes the attributes of a target which could not be read
+// and marks the target as loaded, so it's not read again in the cycle.
+// The failure is never cached beyond the cycle.
+func (n *node) clearAttributes(c *cycle, err error) {
+	n.removeAttributes(nil, "")
+	c.loaded(n.Target)
+	n.Target.Expires = time.Time{}
+	n.Target.Err = newCollectError(err, n.Target.URI)
+}
+
+// findTargets collects the targets the path resolves to which are not fresh
+// in the cycle, keyed by their URI.
+func (n *node) findTargets(c *cycle, names []string, index int, targets map[string]*node) {
+	if index == len(names) {
+		return
+	}
+	if n.Target != nil {
+		n.Target.mutex.Lock()
+		defer n.Target.mutex.Unlock()
+		if !c.fresh(n.Target) {
+			targets[n.Target.URI] = n
+		}
 	}
 
-	return readXMLAttrbutes(contents)
+	for _, token := range strings.Split(names[index], Pipe) {
+		if token == Wildcard {
+			for _, child := range n.Children {
+				child.findTargets(c, names, index+1, targets)
+			}
+		} else if child, _ := n.child(c, token, names, index); child != nil {
+			child.findTargets(c, names, index+1, targets)
+		}
+	}
+	for _, child := range n.tagChildren(c, names[index]) {
+		child.findTargets(c, names, index, targets)
+	}
 }
 
 // Print prints out the tree to the specified depth.
@@ -0,0 +1,196 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeTransport serves the MBeans from memory and counts the reads
+type fakeTransport struct {
+	mbeans map[string][]Attribute
+	reads  int
+}
+
+func (f *fakeTransport) ListMBeans(pattern string) ([]string, error) {
+	domain := strings.TrimSuffix(pattern, ":*")
+	names := []string{}
+	for name := range f.mbeans {
+		if domain == pattern || strings.HasPrefix(name, domain+":") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (f *fakeTransport) ReadMBean(objectname string) ([]Attribute, error) {
+	f.reads++
+	attrs, ok := f.mbeans[objectname]
+	if !ok {
+		return nil, errMBeanNotFound(objectname)
+	}
+	return attrs, nil
+}
+
+func (f *fakeTransport) ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error) {
+	attrs := map[string][]Attribute{}
+	errs := map[string]error{}
+	for _, objectname := range objectnames {
+		if attr, err := f.ReadMBean(objectname); err == nil {
+			attrs[objectname] = attr
+		} else {
+			errs[objectname] = err
+		}
+	}
+	return attrs, errs, nil
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{mbeans: map[string][]Attribute{
+		"org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits": {
+			{Name: "Count", Type: "long", Value: int64(10)},
+			{Name: "OneMinuteRate", Type: "double", Value: 0.5},
+		},
+		"org.apache.cassandra.metrics:type=Cache,scope=RowCache,name=Hits": {
+			{Name: "Count", Type: "long", Value: int64(20)},
+		},
+		"org.apache.cassandra.metrics:type=Cache,scope=CounterCache,name=Hits": {
+			{Name: "Count", Type: "long", Value: int64(30)},
+		},
+	}}
+}
+
+func newFakeClient(transport Transport) *CassClient {
+	cc := NewCassClient("node1", transport)
+	mbeans, _ := transport.ListMBeans(MetricPattern)
+	for _, mbean := range mbeans {
+		cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+	}
+	return cc
+}
+
+func TestNodeGet(t *testing.T) {
+	Convey("Given a tree built from a transport", t, func() {
+		transport := newFakeTransport()
+		cc := newFakeClient(transport)
+
+		Convey("a wildcard should match every MBean", func() {
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), strings.Split("org.apache.cassandra.metrics/type/Cache/scope/*/name/Hits/Count", Slash), &results)
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 3)
+		})
+
+		Convey("a pipe should match the listed MBeans", func() {
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), strings.Split("org.apache.cassandra.metrics/type/Cache/scope/KeyCache|RowCache/name/Hits/Count", Slash), &results)
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 2)
+			So(transport.reads, ShouldEqual, 2)
+		})
+
+		Convey("an exact path should return one value", func() {
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), strings.Split("org.apache.cassandra.metrics/type/Cache/scope/KeyCache/name/Hits/OneMinuteRate", Slash), &results)
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 1)
+			So(results[0].Path, ShouldEqual, "org.apache.cassandra.metrics/type/Cache/scope/KeyCache/name/Hits/OneMinuteRate")
+			So(results[0].Data, ShouldEqual, 0.5)
+		})
+	})
+}
+
+func TestCollectionCycle(t *testing.T) {
+	Convey("Given a tree built from a transport", t, func() {
+		transport := newFakeTransport()
+		cc := newFakeClient(transport)
+		search := strings.Split("org.apache.cassandra.metrics/type/Cache/scope/KeyCache/name/Hits/Count", Slash)
+
+		Convey("an MBean should be read once per cycle", func() {
+			c := cc.newCycle()
+			results := []nodeData{}
+			cc.collect(c, search, &results)
+			cc.collect(c, strings.Split("org.apache.cassandra.metrics/type/Cache/scope/*/name/Hits/Count", Slash), &results)
+			So(results, ShouldHaveLength, 4)
+			So(transport.reads, ShouldEqual, 3)
+		})
+
+		Convey("an MBean should be read again in the next cycle", func() {
+			results := []nodeData{}
+			cc.collect(cc.newCycle(), search, &results)
+			transport.mbeans["org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits"][0].Value = int64(11)
+
+			results = []nodeData{}
+			cc.collect(cc.newCycle(), search, &results)
+			So(transport.reads, ShouldEqual, 2)
+			So(results, ShouldHaveLength, 1)
+			So(results[0].Data, ShouldEqual, 11)
+		})
+
+		Convey("an MBean which can't be read should not return stale values", func() {
+			results := []nodeData{}
+			cc.collect(cc.newCycle(), search, &results)
+			delete(transport.mbeans, "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits")
+
+			results = []nodeData{}
+			err := cc.collect(cc.newCycle(), search, &results)
+			So(results, ShouldBeEmpty)
+			So(err, ShouldNotBeNil)
+			So(classifyError(err), ShouldEqual, MBeanNotFound)
+		})
+
+		Convey("a wildcard should return the MBeans which could be read along with the error", func() {
+			delete(transport.mbeans, "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits")
+
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), strings.Split("org.apache.cassandra.metrics/type/Cache/scope/*/name/Hits/Count", Slash), &results)
+			So(results, ShouldHaveLength, 2)
+			So(err, ShouldNotBeNil)
+			So(err.(*CollectError).MBean, ShouldEqual, "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits")
+		})
+
+		Convey("a failed read should not be cached", func() {
+			cc.cache, _ = newCachePolicy(time.Minute, "scope=KeyCache")
+			transport.mbeans = map[string][]Attribute{}
+			cc.collect(cc.newCycle(), search, &[]nodeData{})
+
+			transport.mbeans = newFakeTransport().mbeans
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), search, &results)
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 1)
+		})
+
+		Convey("a cached MBean should be served until its ttl expires", func() {
+			cc.cache, _ = newCachePolicy(time.Minute, "scope=KeyCache")
+			results := []nodeData{}
+			cc.collect(cc.newCycle(), search, &results)
+			cc.collect(cc.newCycle(), search, &results)
+			So(transport.reads, ShouldEqual, 1)
+			So(results, ShouldHaveLength, 2)
+		})
+	})
+}
@@ -0,0 +1,290 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// const defines constant varaibles
+const (
+	InvalidObjectNameErr = "Invalid ObjectName"
+
+	// propertyListPattern the element of a property list pattern such as d:type=Foo,*
+	propertyListPattern = "*"
+)
+
+// Property is a key property of an ObjectName. The value is the unquoted
+// value, and Quoted tells whether the ObjectName declares it quoted.
+type Property struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+// ObjectName is a JMX ObjectName parsed as the JMX specification defines it,
+// e.g. org.apache.cassandra.metrics:type=Table,keyspace=system,scope="a,b",name=ReadLatency
+type ObjectName struct {
+	Domain string
+	// Properties the key properties in the order the ObjectName declares them
+	Properties []Property
+	// PropertyListPattern the property list ends with a ,* wildcard
+	PropertyListPattern bool
+}
+
+// ParseObjectName parses the ObjectName. The values may be quoted, in which
+// case \" \\ \* \? and \n are unescaped, the keys must be unique and the
+// domain and the unquoted values may hold the * and ? wildcards of a pattern.
+func ParseObjectName(s string) (*ObjectName, error) {
+	sp := strings.SplitN(s, ":", 2)
+	if len(sp) != 2 {
+		return nil, fmt.Errorf("%s: %s: missing domain", InvalidObjectNameErr, s)
+	}
+	on := &ObjectName{Domain: sp[0]}
+	if strings.ContainsAny(on.Domain, "\n") {
+		return nil, fmt.Errorf("%s: %s: invalid domain", InvalidObjectNameErr, s)
+	}
+
+	seen := map[string]bool{}
+	rest := sp[1]
+	for len(rest) > 0 {
+		if rest == propertyListPattern || strings.HasPrefix(rest, propertyListPattern+",") {
+			if on.PropertyListPattern {
+				return nil, fmt.Errorf("%s: %s: repeated wildcard", InvalidObjectNameErr, s)
+			}
+			on.PropertyListPattern = true
+			rest = strings.TrimPrefix(strings.TrimPrefix(rest, propertyListPattern), ",")
+			continue
+		}
+
+		eq := strings.Index(rest, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("%s: %s: invalid key property", InvalidObjectNameErr, s)
+		}
+		key := rest[:eq]
+		if strings.ContainsAny(key, ":,=*?\n") {
+			return nil, fmt.Errorf("%s: %s: invalid key %s", InvalidObjectNameErr, s, key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s: %s: duplicate key %s", InvalidObjectNameErr, s, key)
+		}
+		seen[key] = true
+		rest = rest[eq+1:]
+
+		prop := Property{Key: key}
+		var err error
+		if strings.HasPrefix(rest, "\"") {
+			prop.Quoted = true
+			prop.Value, rest, err = unquoteValue(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", InvalidObjectNameErr, s, err)
+			}
+			if rest != "" && !strings.HasPrefix(rest, ",") {
+				return nil, fmt.Errorf("%s: %s: characters after the quoted value of %s", InvalidObjectNameErr, s, key)
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			prop.Value = rest[:end]
+			rest = rest[end:]
+			if strings.ContainsAny(prop.Value, ":=\"\n") {
+				return nil, fmt.Errorf("%s: %s: invalid value of %s", InvalidObjectNameErr, s, key)
+			}
+		}
+		if prop.Value == "" && !prop.Quoted {
+			return nil, fmt.Errorf("%s: %s: empty value of %s", InvalidObjectNameErr, s, key)
+		}
+		on.Properties = append(on.Properties, prop)
+
+		if strings.HasPrefix(rest, ",") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("%s: %s: trailing comma", InvalidObjectNameErr, s)
+			}
+		}
+	}
+
+	if len(on.Properties) == 0 && !on.PropertyListPattern {
+		return nil, fmt.Errorf("%s: %s: no key properties", InvalidObjectNameErr, s)
+	}
+	return on, nil
+}
+
+// unquoteValue returns the value of the quoted value s starts with,
+// along with the rest of s following the closing quote
+func unquoteValue(s string) (string, string, error) {
+	var b bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", errors.New("unterminated escape")
+			}
+			i++
+			switch s[i] {
+			case '"', '\\', '*', '?':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		case '\n':
+			return "", "", errors.New("newline in quoted value")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", errors.New("unterminated quoted value")
+}
+
+// quoteValue returns the value quoted as the JMX specification defines it
+func quoteValue(value string) string {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\', '*', '?':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// needsQuote returns true if the value can't be written unquoted
+func needsQuote(value string) bool {
+	return value == "" || strings.ContainsAny(value, ",=:\"\n")
+}
+
+// formatValue returns the value as it's written in an ObjectName. A value is
+// quoted if the ObjectName declares it quoted or it can't be written unquoted.
+func (p Property) formatValue() string {
+	if p.Quoted || needsQuote(p.Value) {
+		return quoteValue(p.Value)
+	}
+	return p.Value
+}
+
+// Get returns the unquoted value of the key, or an empty string
+func (on *ObjectName) Get(key string) string {
+	for _, prop := range on.Properties {
+		if prop.Key == key {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// Props returns the unquoted values of the key properties by their keys
+func (on *ObjectName) Props() map[string]string {
+	props := map[string]string{}
+	for _, prop := range on.Properties {
+		props[prop.Key] = prop.Value
+	}
+	return props
+}
+
+// IsPattern returns true if the domain, the property list or a value is a pattern
+func (on *ObjectName) IsPattern() bool {
+	if on.PropertyListPattern || strings.ContainsAny(on.Domain, "*?") {
+		return true
+	}
+	for _, prop := range on.Properties {
+		if !prop.Quoted && strings.ContainsAny(prop.Value, "*?") {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyList returns the key properties in the declared order
+func (on *ObjectName) PropertyList() string {
+	return on.propertyList(on.Properties)
+}
+
+// CanonicalPropertyList returns the key properties in the lexicographic order of their keys
+func (on *ObjectName) CanonicalPropertyList() string {
+	props := append([]Property{}, on.Properties...)
+	sort.Sort(byKey(props))
+	return on.propertyList(props)
+}
+
+// propertyList returns the key properties followed by the wildcard of a property list pattern
+func (on *ObjectName) propertyList(props []Property) string {
+	elems := []string{}
+	for _, prop := range props {
+		elems = append(elems, prop.Key+"="+prop.formatValue())
+	}
+	if on.PropertyListPattern {
+		elems = append(elems, propertyListPattern)
+	}
+	return strings.Join(elems, ",")
+}
+
+// String returns the ObjectName with its key properties in the declared order
+func (on *ObjectName) String() string {
+	return on.Domain + ":" + on.PropertyList()
+}
+
+// Canonical returns the canonical name, the key properties sorted by their keys.
+// Two ObjectNames name the same MBean if their canonical names are equal.
+func (on *ObjectName) Canonical() string {
+	return on.Domain + ":" + on.CanonicalPropertyList()
+}
+
+// byKey sorts the key properties by their keys
+type byKey []Property
+
+func (p byKey) Len() int           { return len(p) }
+func (p byKey) Less(i, j int) bool { return p[i].Key < p[j].Key }
+func (p byKey) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// canonicalName returns the canonical name of the ObjectName,
+// or the ObjectName as it is if it can't be parsed
+func canonicalName(objectname string) string {
+	on, err := ParseObjectName(objectname)
+	if err != nil {
+		return objectname
+	}
+	return on.Canonical()
+}
+
+// objectNameProps returns the domain and the unquoted key properties of the
+// ObjectName, or the ObjectName as the domain if it can't be parsed
+func objectNameProps(objectname string) (string, map[string]string) {
+	on, err := ParseObjectName(objectname)
+	if err != nil {
+		return objectname, map[string]string{}
+	}
+	return on.Domain, on.Props()
+}
@@ -0,0 +1,78 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestObjectName(t *testing.T) {
+	Convey("Given ObjectNames", t, func() {
+		Convey("the key properties should be parsed in the declared order", func() {
+			on, err := ParseObjectName("org.apache.cassandra.metrics:type=Table,keyspace=system,scope=peers,name=ReadLatency")
+			So(err, ShouldBeNil)
+			So(on.Domain, ShouldEqual, "org.apache.cassandra.metrics")
+			So(on.Properties, ShouldHaveLength, 4)
+			So(on.Properties[1], ShouldResemble, Property{Key: "keyspace", Value: "system"})
+			So(on.IsPattern(), ShouldBeFalse)
+		})
+
+		Convey("quoted values should be unescaped and quoted back", func() {
+			name := `org.apache.cassandra.db:type=Tables,scope="/var/lib/cassandra:data",name="a,b=\"c\"\*"`
+			on, err := ParseObjectName(name)
+			So(err, ShouldBeNil)
+			So(on.Get("scope"), ShouldEqual, "/var/lib/cassandra:data")
+			So(on.Get("name"), ShouldEqual, `a,b="c"*`)
+			So(on.IsPattern(), ShouldBeFalse)
+			So(on.String(), ShouldEqual, name)
+			So(makeLitteralNamespace(name, "Count"), ShouldResemble, []string{"org.apache.cassandra.db",
+				"type", "Tables", "scope", "/var/lib/cassandra:data", "name", `a,b="c"*`, "Count"})
+		})
+
+		Convey("the canonical name should not depend on the order of the keys", func() {
+			So(canonicalName("d:type=Table,name=ReadLatency,keyspace=system"), ShouldEqual,
+				canonicalName("d:keyspace=system,name=ReadLatency,type=Table"))
+			So(canonicalName("d:type=Table,name=ReadLatency,keyspace=system"), ShouldEqual,
+				"d:keyspace=system,name=ReadLatency,type=Table")
+		})
+
+		Convey("patterns should be recognized", func() {
+			for _, name := range []string{"java.lang:type=MemoryPool,name=*", "org.apache.cassandra.metrics:*",
+				"d:type=Table,*", "org.apache.*:type=Table"} {
+				on, err := ParseObjectName(name)
+				So(err, ShouldBeNil)
+				So(on.IsPattern(), ShouldBeTrue)
+				So(on.String(), ShouldEqual, name)
+			}
+		})
+
+		Convey("invalid ObjectNames should be rejected", func() {
+			for _, name := range []string{"nodomain", "d:", "d:type", "d:type=a,type=b", "d:type=a,",
+				`d:name="unterminated`, `d:name="a"b`, "d:name=a:b", `d:name="\x"`} {
+				_, err := ParseObjectName(name)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
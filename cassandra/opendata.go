@@ -0,0 +1,172 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+)
+
+// const defines constant varaibles
+const (
+	// CompositeDataType the type of CompositeData attributes
+	CompositeDataType = "javax.management.openmbean.CompositeData"
+	// TabularDataType the type of TabularData attributes
+	TabularDataType = "javax.management.openmbean.TabularData"
+
+	compositePrefix = "javax.management.openmbean.CompositeDataSupport("
+	tabularPrefix   = "javax.management.openmbean.TabularDataSupport("
+	contentsPrefix  = "contents="
+)
+
+// flattenAttributes returns the leaf attributes, the items of composite and
+// tabular attributes named by their path such as HeapMemoryUsage/used.
+// Items whose names contain a slash can't be told apart from the path and
+// are left out.
+func flattenAttributes(attrs []Attribute) []Attribute {
+	leaves := []Attribute{}
+	for _, attr := range attrs {
+		if strings.Contains(attr.Name, Slash) {
+			continue
+		}
+		if len(attr.Items) == 0 {
+			leaves = append(leaves, attr)
+			continue
+		}
+		for _, item := range flattenAttributes(attr.Items) {
+			item.Name = attr.Name + Slash + item.Name
+			leaves = append(leaves, item)
+		}
+	}
+	return leaves
+}
+
+// hasOpenData returns true if any of the attributes is declared
+// as a composite or tabular attribute
+func hasOpenData(attrs []Attribute) bool {
+	for _, attr := range attrs {
+		if attr.Type == CompositeDataType || attr.Type == TabularDataType {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOpenData parses the items of a CompositeData or TabularData value as
+// rendered by its toString method, which is how MX4J reports it, e.g.
+//   javax.management.openmbean.CompositeDataSupport(compositeType=...,contents={committed=1, used=2})
+// The rows of a TabularData are named after their index, and the rows of a
+// map like TabularData, with only a key and a value item, are replaced by the value.
+// It returns false if the value is not a CompositeData or TabularData.
+func parseOpenData(value string) ([]Attribute, bool) {
+	var tabular bool
+	switch {
+	case strings.HasPrefix(value, compositePrefix):
+		value = strings.TrimPrefix(value, compositePrefix)
+	case strings.HasPrefix(value, tabularPrefix):
+		value = strings.TrimPrefix(value, tabularPrefix)
+		tabular = true
+	default:
+		return nil, false
+	}
+
+	var contents string
+	for _, arg := range splitTopLevel(strings.TrimSuffix(value, ")"), ",") {
+		if strings.HasPrefix(arg, contentsPrefix) {
+			contents = strings.TrimPrefix(arg, contentsPrefix)
+		}
+	}
+	contents = strings.TrimSuffix(strings.TrimPrefix(contents, "{"), "}")
+
+	items := []Attribute{}
+	for _, entry := range splitTopLevel(contents, ", ") {
+		kv := splitTopLevel(entry, "=")
+		if len(kv) < 2 {
+			continue
+		}
+		name, val := kv[0], strings.Join(kv[1:], "=")
+		item := parseOpenDataItem(name, val)
+		if tabular {
+			item = tabularRow(item)
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// parseOpenDataItem returns the item of the name parsed from its value
+func parseOpenDataItem(name, value string) Attribute {
+	if items, ok := parseOpenData(value); ok {
+		return Attribute{Name: name, Type: CompositeDataType, Items: items}
+	}
+	if n := parseNumber(value); n != nil {
+		return Attribute{Name: name, Type: numberType(n), Value: n}
+	}
+	return Attribute{Name: name, Type: JavaStringType, Value: value}
+}
+
+// tabularRow names the row after its index and replaces the row of
+// a map like TabularData by its value
+func tabularRow(row Attribute) Attribute {
+	row.Name = strings.TrimSuffix(strings.TrimPrefix(row.Name, "["), "]")
+	if len(row.Items) != 2 {
+		return row
+	}
+
+	var value *Attribute
+	for i, item := range row.Items {
+		switch item.Name {
+		case "key":
+		case "value":
+			value = &row.Items[i]
+		default:
+			return row
+		}
+	}
+	if value == nil {
+		return row
+	}
+	v := *value
+	v.Name = row.Name
+	return v
+}
+
+// splitTopLevel splits s around the separators which are not
+// enclosed in parentheses, braces or brackets
+func splitTopLevel(s, sep string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		default:
+			if depth == 0 && strings.HasPrefix(s[i:], sep) {
+				parts = append(parts, s[start:i])
+				start = i + len(sep)
+				i += len(sep) - 1
+			}
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
@@ -0,0 +1,125 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	memoryUsageType = "javax.management.openmbean.CompositeType(name=java.lang.management.MemoryUsage," +
+		"items=((itemName=committed,itemType=javax.management.openmbean.SimpleType(name=java.lang.Long))," +
+		"(itemName=init,itemType=javax.management.openmbean.SimpleType(name=java.lang.Long))," +
+		"(itemName=max,itemType=javax.management.openmbean.SimpleType(name=java.lang.Long))," +
+		"(itemName=used,itemType=javax.management.openmbean.SimpleType(name=java.lang.Long))))"
+
+	heapMemoryUsage = "javax.management.openmbean.CompositeDataSupport(compositeType=" + memoryUsageType +
+		",contents={committed=2058354688, init=2088763392, max=2058354688, used=160581136})"
+
+	lastGcInfo = "javax.management.openmbean.CompositeDataSupport(compositeType=javax.management.openmbean.CompositeType(name=sun.management.ParNew.GcInfoCompositeType,items=(...))," +
+		"contents={GcThreadCount=4, duration=12, endTime=5200, id=7, " +
+		"memoryUsageAfterGc=javax.management.openmbean.TabularDataSupport(tabularType=javax.management.openmbean.TabularType(name=Map<java.lang.String, java.lang.management.MemoryUsage>,rowType=...,indexNames=(key))," +
+		"contents={[Par Eden Space]=javax.management.openmbean.CompositeDataSupport(compositeType=...," +
+		"contents={key=Par Eden Space, value=" + heapMemoryUsage + "})}), startTime=5188})"
+)
+
+func TestOpenData(t *testing.T) {
+	Convey("Given composite and tabular attribute values", t, func() {
+		Convey("the items of a CompositeData should be parsed", func() {
+			items, ok := parseOpenData(heapMemoryUsage)
+			So(ok, ShouldBeTrue)
+			So(items, ShouldHaveLength, 4)
+
+			leaves := flattenAttributes([]Attribute{{Name: "HeapMemoryUsage", Type: CompositeDataType, Items: items}})
+			values := map[string]interface{}{}
+			for _, leaf := range leaves {
+				values[leaf.Name] = leaf.Value
+			}
+			So(values["HeapMemoryUsage/used"], ShouldEqual, 160581136)
+			So(values["HeapMemoryUsage/max"], ShouldEqual, 2058354688)
+		})
+
+		Convey("the rows of a TabularData should be named after their key", func() {
+			items, ok := parseOpenData(lastGcInfo)
+			So(ok, ShouldBeTrue)
+
+			names := []string{}
+			for _, leaf := range flattenAttributes([]Attribute{{Name: "LastGcInfo", Items: items}}) {
+				names = append(names, leaf.Name)
+			}
+			So(names, ShouldContain, "LastGcInfo/duration")
+			So(names, ShouldContain, "LastGcInfo/memoryUsageAfterGc/Par Eden Space/used")
+		})
+
+		Convey("a plain value should not be parsed", func() {
+			_, ok := parseOpenData("[a, b]")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("the items should be collected as children of the attribute", func() {
+			transport := &fakeTransport{mbeans: map[string][]Attribute{}}
+			cc := NewCassClient("node1", transport)
+			mbean := "java.lang:type=Memory"
+			items, _ := parseOpenData(heapMemoryUsage)
+			transport.mbeans[mbean] = []Attribute{
+				{Name: "HeapMemoryUsage", Type: CompositeDataType, Text: heapMemoryUsage, Items: items},
+				{Name: "ObjectPendingFinalizationCount", Type: "int", Value: int64(0)},
+			}
+			cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+
+			results := []nodeData{}
+			err := cc.collect(cc.newCycle(), strings.Split("java.lang/type/Memory/HeapMemoryUsage/*", Slash), &results)
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 4)
+
+			Convey("and removed once they're no longer reported", func() {
+				transport.mbeans[mbean] = transport.mbeans[mbean][1:]
+				results := []nodeData{}
+				cc.collect(cc.newCycle(), strings.Split("java.lang/type/Memory/*", Slash), &results)
+				So(results, ShouldHaveLength, 1)
+				So(cc.Root.Children["java.lang"].Children["type"].Children["Memory"].Children, ShouldNotContainKey, "HeapMemoryUsage")
+			})
+		})
+
+		Convey("Jolokia objects should be flattened the same way", func() {
+			attrs := makeJolokiaAttributes(map[string]interface{}{
+				"HeapMemoryUsage": map[string]interface{}{"used": 1.0, "max": 2.0},
+			}, map[string]string{"HeapMemoryUsage": CompositeDataType})
+			leaves := flattenAttributes(attrs)
+			So(leaves, ShouldHaveLength, 2)
+			So(leaves[0].Name, ShouldStartWith, "HeapMemoryUsage/")
+		})
+
+		Convey("Jolokia objects of other maps should not be flattened", func() {
+			attrs := makeJolokiaAttributes(map[string]interface{}{
+				"LoadMap": map[string]interface{}{"127.0.0.1": "1.2 MB"},
+			}, map[string]string{"LoadMap": "java.util.Map"})
+			leaves := flattenAttributes(attrs)
+			So(leaves, ShouldHaveLength, 1)
+			So(leaves[0].Name, ShouldEqual, "LoadMap")
+			So(leaves[0].Type, ShouldEqual, "java.util.Map")
+		})
+	})
+}
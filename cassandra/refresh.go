@@ -0,0 +1,103 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// DefaultRefreshInterval the default interval the tree is merged with the node's MBeans at
+	DefaultRefreshInterval = 10 * time.Minute
+
+	RefreshTreeErr = "Refresh of the node's MBeans failed"
+)
+
+// refreshDue returns true if the tree should be merged with the MBeans the
+// node lists. Only the caller it returns true to refreshes the tree.
+func (cc *CassClient) refreshDue() bool {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	if cc.refreshInterval <= 0 || time.Since(cc.refreshed) < cc.refreshInterval {
+		return false
+	}
+	cc.refreshed = time.Now()
+	return true
+}
+
+// refreshTree merges the metric MBeans the node lists into the tree, so the
+// keyspaces, tables and thread pools created since the tree was built are
+// found by the wildcards, and removes the metric MBeans the node no longer
+// lists. The tree is kept as it is if the node can't list its MBeans.
+// The listed MBeans are saved as the catalog of the node's current version.
+func (cc *CassClient) refreshTree(c *cycle) {
+	if c.check() != nil {
+		return
+	}
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "refreshTree",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(RefreshTreeErr)
+		c.failed(err)
+		return
+	}
+	// a node which is starting may not have registered its metrics yet
+	if len(mbeans) == 0 {
+		return
+	}
+	// the version is only written here, by the one caller refreshing the tree
+	version, err := readNodeVersion(cc.transport)
+	if err != nil {
+		version = cc.version
+	}
+	cc.saveCatalog(version, mbeans)
+
+	listed := map[string]bool{}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	cc.version = version
+	for _, mbean := range mbeans {
+		cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+		listed[canonicalName(mbean)] = true
+	}
+	if domain, ok := cc.Root.Children[MetricDomain]; ok {
+		domain.prune(listed)
+	}
+}
+
+// prune removes the targets below the node which are not in listed, which
+// holds the canonical names of the MBeans, along with the nodes left empty.
+func (n *node) prune(listed map[string]bool) {
+	for name, child := range n.Children {
+		if child.Target != nil && !listed[canonicalName(child.Target.URI)] {
+			child.removeAttributes(nil, "")
+			child.Target = nil
+		}
+		child.prune(listed)
+		if child.Target == nil && child.Data == nil && len(child.Children) == 0 {
+			delete(n.Children, name)
+		}
+	}
+}
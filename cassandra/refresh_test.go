@@ -0,0 +1,67 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRefreshTree(t *testing.T) {
+	Convey("Given a node whose tree is refreshed", t, func() {
+		transport := newFakeTransport()
+		cc := newFakeClient(transport)
+		cc.refreshInterval = time.Minute
+
+		collect := func() []plugin.MetricType {
+			ns := core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics", "type", "Cache", "scope", "*", "name", "Hits", "Count")
+			metrics, errs := cc.collectMetrics([]plugin.MetricType{{Namespace_: ns}})
+			So(errs, ShouldBeEmpty)
+			return metrics
+		}
+
+		Convey("an MBean created since the tree was built should be collected", func() {
+			transport.mbeans["org.apache.cassandra.metrics:type=Cache,scope=ChunkCache,name=Hits"] = []Attribute{
+				{Name: "Count", Type: "long", Value: int64(30)},
+			}
+			So(collect(), ShouldHaveLength, 4)
+		})
+
+		Convey("an MBean the node no longer lists should be removed", func() {
+			delete(transport.mbeans, "org.apache.cassandra.metrics:type=Cache,scope=RowCache,name=Hits")
+			So(collect(), ShouldHaveLength, 2)
+			So(cc.Root.Children[MetricDomain].Children["type"].Children["Cache"].Children["scope"].Children, ShouldNotContainKey, "RowCache")
+		})
+
+		Convey("the tree should not be refreshed before the interval elapsed", func() {
+			collect()
+			transport.mbeans["org.apache.cassandra.metrics:type=Cache,scope=ChunkCache,name=Hits"] = []Attribute{
+				{Name: "Count", Type: "long", Value: int64(30)},
+			}
+			So(collect(), ShouldHaveLength, 3)
+		})
+	})
+}
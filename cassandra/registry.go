@@ -0,0 +1,144 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultIdleTimeout the time after which the clusters of a config
+// no task collects anymore are evicted
+const DefaultIdleTimeout = 10 * time.Minute
+
+// registry keeps one cluster per effective config, so that tasks collecting
+// different clusters from the same plugin process don't share clients.
+type registry struct {
+	mutex    sync.Mutex
+	clusters map[string]*registryEntry
+	idle     time.Duration
+}
+
+// registryEntry defines a cluster along with the time it was last used.
+// The cluster is built under the lock of its entry, so the clusters of the
+// other configs are got while it connects to its nodes.
+type registryEntry struct {
+	mutex   sync.Mutex
+	cluster *cluster
+	used    time.Time
+}
+
+// newRegistry returns a new instance of registry evicting
+// the clusters which have been idle for the given time
+func newRegistry(idle time.Duration) *registry {
+	return &registry{clusters: map[string]*registryEntry{}, idle: idle}
+}
+
+// get returns the cluster of the config, it's initialized on first use and
+// built again on the next use if it fails. The clusters which have been idle
+// too long are evicted and their transports closed.
+func (r *registry) get(cfg *cdata.ConfigDataNode) (*cluster, error) {
+	entry, evicted := r.entry(configKey(cfg))
+	for _, e := range evicted {
+		e.close()
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.cluster == nil {
+		cl, err := newCluster(plugin.ConfigType{ConfigDataNode: cfg})
+		if err != nil {
+			return nil, err
+		}
+		err = cl.init()
+		if err != nil {
+			cl.close()
+			return nil, err
+		}
+		entry.cluster = cl
+	}
+	return entry.cluster, nil
+}
+
+// entry returns the entry of the key, added if it's missing, along with
+// the other entries which have been idle too long, removed from the registry
+func (r *registry) entry(key string) (*registryEntry, []*registryEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	evicted := []*registryEntry{}
+	for k, entry := range r.clusters {
+		if k != key && now.Sub(entry.used) > r.idle {
+			delete(r.clusters, k)
+			evicted = append(evicted, entry)
+		}
+	}
+
+	entry, ok := r.clusters[key]
+	if !ok {
+		entry = &registryEntry{}
+		r.clusters[key] = entry
+	}
+	entry.used = now
+	return entry, evicted
+}
+
+// close closes the transports of the cluster of the evicted entry,
+// once it's built if it's being built
+func (e *registryEntry) close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.cluster == nil {
+		return
+	}
+	e.cluster.close()
+	cassLog.WithFields(log.Fields{
+		"_block": "get",
+		"nodes":  strings.Join(e.cluster.seeds, ","),
+	}).Info("Idle cluster evicted")
+}
+
+// configKey returns the key of the effective config. Every config item is part
+// of the key, since each of them changes how the cluster is collected.
+func configKey(cfg *cdata.ConfigDataNode) string {
+	if cfg == nil {
+		return ""
+	}
+	table := cfg.Table()
+
+	keys := []string{}
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []string{}
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("%s=%#v", k, table[k]))
+	}
+	return strings.Join(items, ";")
+}
@@ -0,0 +1,115 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// const defines constant varaibles
+const (
+	// StateSuffix is appended to the name of an attribute
+	// to name its state metric
+	StateSuffix = "State"
+	// UnknownState the state of a value not in the enum
+	UnknownState = -1
+)
+
+// stateMBeans are the MBeans outside of the metric domain which report
+// the state of the node. They're added into the tree of every node.
+var stateMBeans = []string{StorageServiceMBean}
+
+// stateEnums maps the values of the enum attributes to their state
+// numbers, in the order the enum is declared by Cassandra
+var stateEnums = map[string]map[string]int{
+	"OperationMode": {
+		"STARTING":       0,
+		"NORMAL":         1,
+		"JOINING":        2,
+		"LEAVING":        3,
+		"DECOMMISSIONED": 4,
+		"MOVING":         5,
+		"DRAINING":       6,
+		"DRAINED":        7,
+	},
+}
+
+// stateCatalog defines the state attributes of the StorageService
+// listed by GetMetricTypes along with their types
+var stateCatalog = map[string]string{
+	"OperationMode":          JavaStringType,
+	"ReleaseVersion":         JavaStringType,
+	"SchemaVersion":          JavaStringType,
+	"Joined":                 BooleanType,
+	"GossipRunning":          BooleanType,
+	"NativeTransportRunning": BooleanType,
+}
+
+// withStates returns the attributes along with a state metric for
+// every enum and boolean attribute, so that dashboards can graph them
+func withStates(attrs []Attribute) []Attribute {
+	states := []Attribute{}
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case bool:
+			var state int64
+			if v {
+				state = 1
+			}
+			states = append(states, Attribute{Name: attr.Name + StateSuffix, Type: "int", Value: state})
+		case string:
+			if stateEnums[attr.Name] == nil {
+				continue
+			}
+			state, ok := stateEnums[attr.Name][v]
+			if !ok {
+				state = UnknownState
+			}
+			states = append(states, Attribute{Name: attr.Name + StateSuffix, Type: "int", Value: int64(state)})
+		}
+	}
+	return append(attrs, states...)
+}
+
+// stateMetricTypes returns the metric types of the state catalog
+func stateMetricTypes() []plugin.MetricType {
+	mts := []plugin.MetricType{}
+	for name, typ := range stateCatalog {
+		mts = append(mts, plugin.MetricType{
+			Namespace_:   makeDynamicNamespace("", StorageServiceMBean, name),
+			Description_: metricDescription(name),
+		})
+		if typ == BooleanType || stateEnums[name] != nil {
+			mts = append(mts, plugin.MetricType{
+				Namespace_:   makeDynamicNamespace("", StorageServiceMBean, name+StateSuffix),
+				Description_: metricDescription(name + StateSuffix),
+			})
+		}
+	}
+	return mts
+}
+
+// addStateTargets adds the state MBeans into the tree
+func (cc *CassClient) addStateTargets() {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	for _, mbean := range stateMBeans {
+		cc.Root.Add(makeLitteralNamespace(mbean, ""), 0, mbean)
+	}
+}
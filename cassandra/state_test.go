@@ -0,0 +1,84 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStateMetrics(t *testing.T) {
+	Convey("Given a node reporting its state", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans[StorageServiceMBean] = []Attribute{
+			{Name: "OperationMode", Type: JavaStringType, Value: "NORMAL"},
+			{Name: "ReleaseVersion", Type: JavaStringType, Value: "3.11.4"},
+			{Name: "Joined", Type: BooleanType, Value: true},
+			{Name: "GossipRunning", Type: "java.lang.Boolean", Value: false},
+			{Name: "LiveNodes", Type: "java.util.List", Text: "[127.0.0.1]"},
+		}
+		cc := newFakeClient(transport)
+		cc.addStateTargets()
+
+		mts := []plugin.MetricType{{
+			Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_db",
+				"type", "StorageService", "*"),
+		}}
+		collect := func() map[string]interface{} {
+			metrics, errs := cc.collectMetrics(mts)
+			So(errs, ShouldBeEmpty)
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				ns := m.Namespace().Strings()
+				values[ns[len(ns)-1]] = m.Data()
+			}
+			return values
+		}
+
+		Convey("strings and booleans should be collected with their types", func() {
+			values := collect()
+			So(values, ShouldHaveLength, 4)
+			So(values["OperationMode"], ShouldEqual, "NORMAL")
+			So(values["ReleaseVersion"], ShouldEqual, "3.11.4")
+			So(values["Joined"], ShouldEqual, true)
+			So(values["GossipRunning"], ShouldEqual, false)
+		})
+
+		Convey("the state metrics should map the values to numbers", func() {
+			cc.states = true
+			values := collect()
+			So(values, ShouldHaveLength, 7)
+			So(values["OperationModeState"], ShouldEqual, 1)
+			So(values["JoinedState"], ShouldEqual, 1)
+			So(values["GossipRunningState"], ShouldEqual, 0)
+		})
+
+		Convey("an unknown enum value should be the unknown state", func() {
+			transport.mbeans[StorageServiceMBean][0].Value = "RESUMING"
+			cc.states = true
+			So(collect()["OperationModeState"], ShouldEqual, UnknownState)
+		})
+	})
+}
@@ -0,0 +1,96 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// tagKeys are the ObjectName keys whose values are reported as tags
+// rather than namespace elements in tag mode. Their values are the
+// keyspaces and tables, which have a high cardinality.
+var tagKeys = map[string]bool{
+	"keyspace": true,
+	"scope":    true,
+	"table":    true,
+}
+
+// tagChildren returns the value nodes under the tag keys of the node which
+// aren't requested by the name. In tag mode the path doesn't hold the tag
+// keys, so they are passed through as if their values were wildcards.
+func (n *node) tagChildren(c *cycle, name string) []*node {
+	if !c.tags {
+		return nil
+	}
+	children := []*node{}
+	for key := range tagKeys {
+		if key == name {
+			continue
+		}
+		if tag, ok := n.Children[key]; ok {
+			for _, child := range tag.Children {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// taggedNamespace returns the namespace of the data point without the tag
+// keys of its MBean, along with their values as tags
+func taggedNamespace(data nodeData) ([]string, map[string]string) {
+	lit := data.mbeanElements()
+
+	ns := []string{lit[0]}
+	tags := map[string]string{}
+	for i := 1; i+1 < len(lit); i += 2 {
+		if tagKeys[lit[i]] {
+			tags[lit[i]] = lit[i+1]
+			continue
+		}
+		ns = append(ns, lit[i], lit[i+1])
+	}
+	return append(ns, strings.Split(data.Attr, Slash)...), tags
+}
+
+// untagMetricTypes returns the catalog in tag mode, the tag keys and their
+// dynamic values removed from the namespaces, each namespace once
+func untagMetricTypes(mts []plugin.MetricType) []plugin.MetricType {
+	untagged := []plugin.MetricType{}
+	for _, mt := range mts {
+		ns := mt.Namespace()
+		if len(ns) < 6 {
+			untagged = append(untagged, mt)
+			continue
+		}
+
+		kept := append(ns[:0:0], ns[:5]...)
+		i := 5
+		for ; i+1 < len(ns)-1 && ns[i+1].IsDynamic(); i += 2 {
+			if !tagKeys[decodeElement(ns[i].Value)] {
+				kept = append(kept, ns[i], ns[i+1])
+			}
+		}
+		mt.Namespace_ = append(kept, ns[i:]...)
+		untagged = append(untagged, mt)
+	}
+	return mergeMetricTypes(untagged, nil)
+}
@@ -0,0 +1,79 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagMode(t *testing.T) {
+	Convey("Given a node collected in tag mode", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans["org.apache.cassandra.metrics:type=Table,keyspace=system_auth,scope=roles,name=ReadLatency"] = []Attribute{
+			{Name: "Count", Type: "long", Value: int64(5)},
+		}
+		transport.mbeans["org.apache.cassandra.metrics:type=Table,keyspace=system,scope=peers,name=ReadLatency"] = []Attribute{
+			{Name: "Count", Type: "long", Value: int64(7)},
+		}
+		cc := newFakeClient(transport)
+		cc.tags = true
+
+		collect := func(elems ...string) []plugin.MetricType {
+			ns := append([]string{"intel", "cassandra", "node", "*", "org_apache_cassandra_metrics"}, elems...)
+			metrics, errs := cc.collectMetrics([]plugin.MetricType{{Namespace_: core.NewNamespace(ns...)}})
+			So(errs, ShouldBeEmpty)
+			return metrics
+		}
+
+		Convey("the keyspaces and tables should be tags of a namespace without them", func() {
+			metrics := collect("type", "Table", "name", "ReadLatency", "Count")
+			So(metrics, ShouldHaveLength, 2)
+			tables := map[string]interface{}{}
+			for _, m := range metrics {
+				So(m.Namespace().String(), ShouldEqual, "/intel/cassandra/node/node1/org.apache.cassandra.metrics/type/Table/name/ReadLatency/Count")
+				tables[m.Tags()["keyspace"]+"."+m.Tags()["scope"]] = m.Data()
+			}
+			So(tables["system_auth.roles"], ShouldEqual, int64(5))
+			So(tables["system.peers"], ShouldEqual, int64(7))
+		})
+
+		Convey("a requested keyspace should still select its tables", func() {
+			metrics := collect("type", "Table", "keyspace", "system", "name", "ReadLatency", "Count")
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Tags()["scope"], ShouldEqual, "peers")
+		})
+
+		Convey("the catalog should leave out the tag keys", func() {
+			mts := untagMetricTypes([]plugin.MetricType{
+				{Namespace_: makeDynamicNamespace("", "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=*", "Count")},
+				{Namespace_: makeDynamicNamespace("", "org.apache.cassandra.metrics:type=Keyspace,keyspace=*,name=*", "Count")},
+			})
+			So(mts, ShouldHaveLength, 1)
+			So(mts[0].Namespace().Strings(), ShouldResemble, []string{"intel", "cassandra", "node", "*",
+				"org_apache_cassandra_metrics", "type", "*", "name", "*", "Count"})
+		})
+	})
+}
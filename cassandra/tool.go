@@ -0,0 +1,353 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// const defines constant varaibles
+const (
+	// MetricsDoc the document listing the metrics of a node
+	MetricsDoc = "METRICS.md"
+	// DynamicMetricsDoc the document listing the dynamic metric queries
+	DynamicMetricsDoc = "DYNAMIC_METRICS.md"
+
+	// docNodeName the node name of the metrics listed in MetricsDoc
+	docNodeName = "<name>"
+)
+
+// NewConfig returns the config of the items given as strings, integers and booleans,
+// so the catalog tool connects to a node as the plugin does
+func NewConfig(items map[string]interface{}) plugin.ConfigType {
+	cfg := plugin.ConfigType{ConfigDataNode: cdata.NewNode()}
+	for key, item := range items {
+		switch v := item.(type) {
+		case string:
+			cfg.AddItem(key, ctypes.ConfigValueStr{Value: v})
+		case int:
+			cfg.AddItem(key, ctypes.ConfigValueInt{Value: v})
+		case bool:
+			cfg.AddItem(key, ctypes.ConfigValueBool{Value: v})
+		}
+	}
+	return cfg
+}
+
+// newToolClient returns the client of the first node of the config, along
+// with the function closing its transport and the cluster
+func newToolClient(cfg plugin.ConfigType) (*CassClient, func(), error) {
+	cl, err := newCluster(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cc, err := cl.newClient(cl.seeds[0])
+	if err != nil {
+		cl.close()
+		return nil, nil, err
+	}
+	return cc, func() {
+		cc.close()
+		cl.close()
+	}, nil
+}
+
+// DumpCatalog writes the searchable tree of the metric MBeans the node
+// lists, in the format of the embedded CassandraMetricAPI.json
+func DumpCatalog(cfg plugin.ConfigType, w io.Writer) error {
+	cc, close, err := newToolClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer close()
+	return cc.dumpCatalog(w)
+}
+
+// DumpCatalogDir writes the catalog of the node's major version in the
+// directory, as the embedded catalogs are laid out: the searchable tree and
+// the metric types in a directory named after the version, e.g. 4.0/.
+// It returns the directory written.
+func DumpCatalogDir(cfg plugin.ConfigType, dir string) (string, error) {
+	cc, close, err := newToolClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer close()
+	return cc.dumpCatalogDir(dir)
+}
+
+// DiffCatalog writes the metric MBeans the node lists which the embedded
+// catalog of its version lacks, prefixed with +, and the ones the catalog
+// holds which the node doesn't list, prefixed with -. It returns the number
+// of MBeans which differ.
+func DiffCatalog(cfg plugin.ConfigType, w io.Writer) (int, error) {
+	cc, close, err := newToolClient(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer close()
+	return cc.diffCatalog(w)
+}
+
+// WriteMetricDocs regenerates METRICS.md and DYNAMIC_METRICS.md in the
+// directory from the MBeans the node lists and their attributes
+func WriteMetricDocs(cfg plugin.ConfigType, dir string) error {
+	cc, close, err := newToolClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer close()
+	return cc.writeMetricDocs(dir)
+}
+
+func (cc *CassClient) dumpCatalog(w io.Writer) error {
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
+	if err != nil {
+		return err
+	}
+	tree, err := json.MarshalIndent(newTree(mbeans), "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(tree)
+	return err
+}
+
+func (cc *CassClient) dumpCatalogDir(dir string) (string, error) {
+	version, err := readNodeVersion(cc.transport)
+	if err != nil {
+		return "", err
+	}
+	path := catalogPath(dir, version.Release)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
+	if err != nil {
+		return "", err
+	}
+	if err := writeMetricAPIs(filepath.Join(path, MetricAPIFile), newTree(mbeans)); err != nil {
+		return "", err
+	}
+	types, err := cc.buildMetricType()
+	if err != nil {
+		return "", err
+	}
+	return path, writeMetricTypes(path, types)
+}
+
+func (cc *CassClient) diffCatalog(w io.Writer) (int, error) {
+	mbeans, err := cc.transport.ListMBeans(MetricPattern)
+	if err != nil {
+		return 0, err
+	}
+	c := defaultCatalog
+	if version, err := readNodeVersion(cc.transport); err == nil {
+		if vc, ok := catalogs[majorVersion(version.Release)]; ok {
+			c = vc
+		}
+	}
+	tree, err := readMetricAPI(c.tree)
+	if err != nil {
+		return 0, err
+	}
+
+	embedded := map[string]bool{}
+	tree.targets(embedded)
+	listed := map[string]bool{}
+	for _, mbean := range mbeans {
+		listed[canonicalName(mbean)] = true
+	}
+
+	lines := []string{}
+	for name := range listed {
+		if !embedded[name] {
+			lines = append(lines, "+ "+name)
+		}
+	}
+	for name := range embedded {
+		if !listed[name] {
+			lines = append(lines, "- "+name)
+		}
+	}
+	sort.Sort(byMBean(lines))
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(lines), nil
+}
+
+// byMBean sorts the lines of the diff by their MBeans
+type byMBean []string
+
+func (l byMBean) Len() int           { return len(l) }
+func (l byMBean) Less(i, j int) bool { return l[i][2:] < l[j][2:] }
+func (l byMBean) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// targets adds the canonical names of the targets below the node
+func (n *node) targets(names map[string]bool) {
+	if n.Target != nil {
+		names[canonicalName(n.Target.URI)] = true
+	}
+	for _, child := range n.Children {
+		child.targets(names)
+	}
+}
+
+func (cc *CassClient) writeMetricDocs(dir string) error {
+	mbeans, err := cc.catalogMBeans()
+	if err != nil {
+		return err
+	}
+
+	// the metrics of an MBean are listed in the order the node reports them
+	metrics := map[string][]string{}
+	types := []plugin.MetricType{}
+	for _, mbean := range mbeans {
+		leaves, err := cc.readLeaves(mbean)
+		if err != nil {
+			continue
+		}
+		types = append(types, elementTypes(mbean, leaves)...)
+		// the JVM and state metrics are listed from their catalogs
+		if domain, _ := objectNameProps(mbean); domain != MetricDomain {
+			continue
+		}
+		ns := docNamespace(makeLitteralNamespace(mbean, ""))
+		for _, attr := range leaves {
+			if attr.collectable() {
+				metrics[ns] = append(metrics[ns], ns+Slash+attr.Name)
+			}
+		}
+	}
+	types = mergeMetricTypes(types, append(jvmMetricTypes(), stateMetricTypes()...))
+
+	namespaces := []string{}
+	for ns := range metrics {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	cassandra := []string{}
+	for _, ns := range namespaces {
+		cassandra = append(cassandra, metrics[ns]...)
+	}
+
+	dynamic := []string{}
+	for _, mt := range types {
+		dynamic = append(dynamic, mt.Namespace().String())
+	}
+	sort.Strings(dynamic)
+
+	err = writeDoc(filepath.Join(dir, MetricsDoc),
+		docSection{"Cassandra Metrics may be collected", cassandra},
+		docSection{"JVM Metrics may be collected", jvmDocMetrics()},
+		docSection{"Node State Metrics may be collected", stateDocMetrics()})
+	if err != nil {
+		return err
+	}
+	return writeDoc(filepath.Join(dir, DynamicMetricsDoc), docSection{lines: dynamic})
+}
+
+// jvmDocPlaceholders name the dynamic elements of the JVM metrics in MetricsDoc
+var jvmDocPlaceholders = map[string]string{
+	"MemoryPool":       "<pool>",
+	"GarbageCollector": "<collector>",
+}
+
+// docNamespace returns the namespace of the MBean elements in MetricsDoc
+func docNamespace(elements []string) string {
+	return strings.Join(append([]string{"", "intel", "cassandra", "node", docNodeName}, elements...), Slash)
+}
+
+// jvmDocMetrics returns the metrics of the JVM catalog, the names of the
+// memory pools and the garbage collectors being placeholders
+func jvmDocMetrics() []string {
+	metrics := []string{}
+	for _, mbean := range jvmCatalog {
+		_, props := objectNameProps(mbean.objectname)
+		elements := makeLitteralNamespace(mbean.objectname, "")
+		for i, element := range elements {
+			if element == "*" {
+				elements[i] = jvmDocPlaceholders[props["type"]]
+			}
+		}
+		ns := docNamespace(elements)
+		names := []string{}
+		for name := range mbean.attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			metrics = append(metrics, ns+Slash+name)
+		}
+	}
+	return metrics
+}
+
+// stateDocMetrics returns the metrics of the state catalog
+func stateDocMetrics() []string {
+	ns := docNamespace(makeLitteralNamespace(StorageServiceMBean, ""))
+	metrics := []string{}
+	for _, mt := range stateMetricTypes() {
+		elements := mt.Namespace().Strings()
+		metrics = append(metrics, ns+Slash+elements[len(elements)-1])
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// docSection defines a section of a document, the lines of a code block
+// following the header, if any
+type docSection struct {
+	header string
+	lines  []string
+}
+
+// writeDoc writes the sections one after another
+func writeDoc(path string, sections ...docSection) error {
+	var b bytes.Buffer
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if section.header != "" {
+			b.WriteString(section.header + "\n")
+		}
+		b.WriteString("```\n")
+		for _, line := range section.lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("```\n")
+	}
+	return writeFileAtomic(path, b.Bytes())
+}
@@ -0,0 +1,90 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogTool(t *testing.T) {
+	Convey("Given the catalog tool connected to a node", t, func() {
+		transport := newFakeTransport()
+		transport.mbeans["org.apache.cassandra.metrics:type=Cache,scope=NewCache,name=Hits"] = []Attribute{
+			{Name: "Count", Type: "long", Value: int64(1)},
+		}
+		cc := NewCassClient("node1", transport)
+
+		Convey("the dumped catalog should be a searchable tree of the node's MBeans", func() {
+			var b bytes.Buffer
+			So(cc.dumpCatalog(&b), ShouldBeNil)
+			var tree *node
+			So(json.Unmarshal(b.Bytes(), &tree), ShouldBeNil)
+			names := map[string]bool{}
+			tree.targets(names)
+			So(names, ShouldContainKey, "org.apache.cassandra.metrics:name=Hits,scope=NewCache,type=Cache")
+			So(names, ShouldHaveLength, len(transport.mbeans))
+		})
+
+		Convey("the diff should list the MBeans missing from either catalog", func() {
+			var b bytes.Buffer
+			n, err := cc.diffCatalog(&b)
+			So(err, ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			So(lines, ShouldHaveLength, n)
+			So(lines, ShouldContain, "+ org.apache.cassandra.metrics:name=Hits,scope=NewCache,type=Cache")
+			So(lines, ShouldNotContain, "- org.apache.cassandra.metrics:name=Hits,scope=KeyCache,type=Cache")
+			So(lines, ShouldNotContain, "+ org.apache.cassandra.metrics:name=Hits,scope=KeyCache,type=Cache")
+		})
+
+		Convey("the documents should list the metrics of the node", func() {
+			dir, err := ioutil.TempDir("", "docs")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			So(cc.writeMetricDocs(dir), ShouldBeNil)
+			metrics, err := ioutil.ReadFile(filepath.Join(dir, MetricsDoc))
+			So(err, ShouldBeNil)
+			So(string(metrics), ShouldStartWith, "Cassandra Metrics may be collected\n```\n")
+			So(string(metrics), ShouldContainSubstring, "\n/intel/cassandra/node/<name>/org.apache.cassandra.metrics/type/Cache/scope/NewCache/name/Hits/Count\n")
+			So(string(metrics), ShouldContainSubstring, "```\n\nJVM Metrics may be collected\n```\n")
+			So(string(metrics), ShouldContainSubstring, "\n/intel/cassandra/node/<name>/java.lang/type/MemoryPool/name/<pool>/Usage/used\n")
+			So(string(metrics), ShouldContainSubstring, "\n/intel/cassandra/node/<name>/java.lang/type/GarbageCollector/name/<collector>/CollectionCount\n")
+			So(string(metrics), ShouldContainSubstring, "```\n\nNode State Metrics may be collected\n```\n")
+			So(string(metrics), ShouldContainSubstring, "\n/intel/cassandra/node/<name>/org.apache.cassandra.db/type/StorageService/OperationModeState\n")
+			cassandra := strings.SplitN(string(metrics), "JVM Metrics", 2)[0]
+			So(cassandra, ShouldNotContainSubstring, "java.lang")
+			So(cassandra, ShouldNotContainSubstring, "StorageService")
+			dynamic, err := ioutil.ReadFile(filepath.Join(dir, DynamicMetricsDoc))
+			So(err, ShouldBeNil)
+			So(string(dynamic), ShouldContainSubstring, "\n/intel/cassandra/node/*/org_apache_cassandra_metrics/type/*/scope/*/name/*/Count\n")
+			So(string(dynamic), ShouldEndWith, "```\n")
+		})
+	})
+}
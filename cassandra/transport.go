@@ -0,0 +1,137 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"strconv"
+)
+
+// BooleanType the type of boolean attributes
+const BooleanType = "boolean"
+
+// Attribute represents one MBean attribute read through a transport
+type Attribute struct {
+	Name string
+	Type string
+	// Value the value as an int64, uint64, float64, bool or string
+	// according to the declared type, nil if it's none of them
+	Value interface{}
+	// Text the value as rendered by the node if it's not numeric,
+	// a string or a boolean, such as [a, b] for a list
+	Text string
+	// Items the items of a composite or tabular value, such as
+	// the init, used, committed and max items of a MemoryUsage
+	Items []Attribute
+}
+
+// newAttribute returns the attribute with the value as rendered by
+// the node converted according to the declared type
+func newAttribute(name, typ, text string) Attribute {
+	if value := parseValue(typ, text); value != nil {
+		return Attribute{Name: name, Type: typ, Value: value}
+	}
+	return Attribute{Name: name, Type: typ, Text: text}
+}
+
+// collectable returns true if the attribute is numeric, a string or a boolean.
+// The attributes listed without their values are told apart by their type.
+func (a Attribute) collectable() bool {
+	return a.Value != nil || a.Text == ""
+}
+
+// parseValue returns the value converted according to the declared type.
+// The integer types are kept as int64, or uint64 if they overflow, and the
+// numbers of an undeclared type such as java.lang.Object are parsed as
+// integers if they can be. It returns nil if the value can't be converted.
+func parseValue(typ, text string) interface{} {
+	switch typ {
+	case BooleanType, "java.lang.Boolean":
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+		return nil
+	case JavaStringType:
+		return text
+	case "double", "float", "java.lang.Double", "java.lang.Float":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+		return nil
+	}
+	return parseNumber(text)
+}
+
+// parseNumber returns the number as an int64, an uint64 or a float64,
+// the first one it fits into, or nil if it's not a number
+func parseNumber(text string) interface{} {
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return u
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return nil
+}
+
+// numberType returns the Java type of the number
+func numberType(value interface{}) string {
+	if _, ok := value.(float64); ok {
+		return "double"
+	}
+	return "long"
+}
+
+// Transport reads the MBeans of one Cassandra node. The metric tree
+// and the metric catalog only talk to the node through a Transport.
+type Transport interface {
+	// ListMBeans returns the object names matching the ObjectName pattern
+	ListMBeans(pattern string) ([]string, error)
+	// ReadMBean returns the attributes of one MBean
+	ReadMBean(objectname string) ([]Attribute, error)
+	// ReadMBeans returns the attributes of the given MBeans keyed by object name,
+	// along with the error of every MBean which could not be read. The error is
+	// returned if no MBean could be read, e.g. because the node is unreachable.
+	ReadMBeans(objectnames []string) (map[string][]Attribute, map[string]error, error)
+}
+
+// AttributeLister is implemented by the transports which can report
+// the declared attributes of an MBean without reading their values.
+type AttributeLister interface {
+	// ListAttributes returns the attributes of the MBean with only the name and type set
+	ListAttributes(objectname string) ([]Attribute, error)
+}
+
+// newTransport returns the transport of the given name
+// talking to the server. MX4J reads at most concurrency MBeans at
+// the same time, Jolokia reads them all with one bulk request.
+func newTransport(name, server string, cfg HTTPConfig, concurrency int) (Transport, error) {
+	switch name {
+	case MX4JTransport:
+		m := NewMX4J(NewHTTPClientWithConfig(server, "", cfg))
+		m.concurrency = concurrency
+		return m, nil
+	case JolokiaTransport:
+		return NewJolokia(NewHTTPClientWithConfig(server, JolokiaEndpoint, cfg)), nil
+	}
+	return nil, errors.New(InvalidTransport)
+}
@@ -0,0 +1,74 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAttributeValues(t *testing.T) {
+	Convey("Given attribute values rendered by a node", t, func() {
+		Convey("the values should be converted according to the declared type", func() {
+			So(newAttribute("Count", "long", "9007199254740993").Value, ShouldEqual, int64(9007199254740993))
+			So(newAttribute("Count", "long", "18446744073709551615").Value, ShouldEqual, uint64(18446744073709551615))
+			So(newAttribute("Mean", "double", "12").Value, ShouldEqual, float64(12))
+			So(newAttribute("Joined", "boolean", "true").Value, ShouldEqual, true)
+			So(newAttribute("ReleaseVersion", JavaStringType, "3.11.4").Value, ShouldEqual, "3.11.4")
+			So(newAttribute("Value", "java.lang.Object", "42").Value, ShouldEqual, int64(42))
+			So(newAttribute("Value", "java.lang.Object", "0.25").Value, ShouldEqual, 0.25)
+		})
+
+		Convey("a value which can't be converted should only be kept as text", func() {
+			attr := newAttribute("LiveNodes", "java.util.List", "[127.0.0.1]")
+			So(attr.Value, ShouldBeNil)
+			So(attr.Text, ShouldEqual, "[127.0.0.1]")
+			So(attr.collectable(), ShouldBeFalse)
+		})
+
+		Convey("Jolokia numbers should keep the precision of longs", func() {
+			attrs := makeJolokiaAttributes(map[string]interface{}{"Count": json.Number("9007199254740993")}, nil)
+			So(attrs[0].Value, ShouldEqual, int64(9007199254740993))
+			So(attrs[0].Type, ShouldEqual, "long")
+		})
+
+		Convey("the units should be carried to the collected metrics", func() {
+			transport := newFakeTransport()
+			cc := newFakeClient(transport)
+			metrics, _ := cc.collectMetrics([]plugin.MetricType{{
+				Namespace_: core.NewNamespace("intel", "cassandra", "node", "*", "org_apache_cassandra_metrics",
+					"type", "Cache", "scope", "KeyCache", "name", "Hits", "*"),
+			}})
+			units := map[string]string{}
+			for _, m := range metrics {
+				ns := m.Namespace().Strings()
+				units[ns[len(ns)-1]] = m.Unit()
+			}
+			So(units["Count"], ShouldEqual, UnitCount)
+			So(units["OneMinuteRate"], ShouldEqual, UnitEventsPerSecond)
+		})
+	})
+}
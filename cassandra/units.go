@@ -0,0 +1,246 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// const defines constant varaibles
+const (
+	UnitCount           = "count"
+	UnitBytes           = "bytes"
+	UnitMicroseconds    = "microseconds"
+	UnitMilliseconds    = "milliseconds"
+	UnitNanoseconds     = "nanoseconds"
+	UnitEventsPerSecond = "events/second"
+	UnitRatio           = "ratio"
+)
+
+// rateAttributes are the attributes of a meter
+var rateAttributes = map[string]bool{
+	"OneMinuteRate":     true,
+	"FiveMinuteRate":    true,
+	"FifteenMinuteRate": true,
+	"MeanRate":          true,
+}
+
+// distributionAttributes are the attributes of a timer or a histogram
+var distributionAttributes = map[string]bool{
+	"50thPercentile":  true,
+	"75thPercentile":  true,
+	"95thPercentile":  true,
+	"98thPercentile":  true,
+	"99thPercentile":  true,
+	"999thPercentile": true,
+	"Mean":            true,
+	"Min":             true,
+	"Max":             true,
+	"StdDev":          true,
+}
+
+// jvmUnits are the units of the JVM attributes and composite items
+var jvmUnits = map[string]string{
+	"init":                       UnitBytes,
+	"used":                       UnitBytes,
+	"committed":                  UnitBytes,
+	"max":                        UnitBytes,
+	"CollectionTime":             UnitMilliseconds,
+	"Uptime":                     UnitMilliseconds,
+	"StartTime":                  UnitMilliseconds,
+	"duration":                   UnitMilliseconds,
+	"startTime":                  UnitMilliseconds,
+	"endTime":                    UnitMilliseconds,
+	"ProcessCpuTime":             UnitNanoseconds,
+	"FreePhysicalMemorySize":     UnitBytes,
+	"TotalPhysicalMemorySize":    UnitBytes,
+	"CommittedVirtualMemorySize": UnitBytes,
+	"FreeSwapSpaceSize":          UnitBytes,
+	"TotalSwapSpaceSize":         UnitBytes,
+	"ProcessCpuLoad":             UnitRatio,
+	"SystemCpuLoad":              UnitRatio,
+	"AvailableProcessors":        UnitCount,
+	"GcThreadCount":              UnitCount,
+}
+
+// attributeDescriptions describe the attributes of the metric types
+var attributeDescriptions = map[string]string{
+	"Count":             "The number of events, or the value of a counter",
+	"Value":             "The value of the gauge",
+	"OneMinuteRate":     "The rate of events over the last minute",
+	"FiveMinuteRate":    "The rate of events over the last five minutes",
+	"FifteenMinuteRate": "The rate of events over the last fifteen minutes",
+	"MeanRate":          "The mean rate of events since the metric was created",
+	"50thPercentile":    "The median of the recent values",
+	"75thPercentile":    "The 75th percentile of the recent values",
+	"95thPercentile":    "The 95th percentile of the recent values",
+	"98thPercentile":    "The 98th percentile of the recent values",
+	"99thPercentile":    "The 99th percentile of the recent values",
+	"999thPercentile":   "The 99.9th percentile of the recent values",
+	"Mean":              "The mean of the recent values",
+	"Min":               "The minimum of the recent values",
+	"Max":               "The maximum of the recent values",
+	"StdDev":            "The standard deviation of the recent values",
+
+	"init":                    "The memory initially requested from the operating system",
+	"used":                    "The memory in use",
+	"committed":               "The memory guaranteed to be available to the JVM",
+	"max":                     "The maximum memory which may be used, -1 if undefined",
+	"CollectionCount":         "The number of collections",
+	"CollectionTime":          "The accumulated collection time",
+	"ThreadCount":             "The number of live threads",
+	"PeakThreadCount":         "The peak number of live threads",
+	"DaemonThreadCount":       "The number of live daemon threads",
+	"TotalStartedThreadCount": "The number of threads started since the JVM started",
+	"Uptime":                  "The uptime of the JVM",
+	"StartTime":               "The start time of the JVM since the epoch",
+	"ProcessCpuLoad":          "The recent CPU usage of the JVM process",
+	"SystemCpuLoad":           "The recent CPU usage of the whole system",
+	"ProcessCpuTime":          "The CPU time used by the JVM process",
+	"OpenFileDescriptorCount": "The number of open file descriptors",
+	"MaxFileDescriptorCount":  "The maximum number of file descriptors",
+	"LoadedClassCount":        "The number of classes currently loaded",
+	"TotalLoadedClassCount":   "The number of classes loaded since the JVM started",
+	"UnloadedClassCount":      "The number of classes unloaded since the JVM started",
+
+	"OperationMode":          "The operation mode of the node, such as NORMAL or JOINING",
+	"ReleaseVersion":         "The Cassandra version of the node",
+	"SchemaVersion":          "The schema version of the node",
+	"Joined":                 "Whether the node has joined the ring",
+	"GossipRunning":          "Whether gossip is running",
+	"NativeTransportRunning": "Whether the native transport is running",
+}
+
+// metricUnit returns the unit of the attribute of the MBean, or an empty
+// string if it's unknown. The props are the ObjectName properties, empty
+// for the ones which are dynamic in the catalog, and siblings are the values
+// of the other attributes of the MBean, nil if they haven't been read. The
+// timers report their own unit, which is microseconds unless configured
+// otherwise, and the gauges and histograms are told apart by their name.
+func metricUnit(domain string, props map[string]string, attr string, siblings map[string]interface{}) string {
+	path := strings.Split(attr, Slash)
+	leaf := path[len(path)-1]
+	if domain == JVMDomain {
+		if strings.HasSuffix(leaf, "Count") && jvmUnits[leaf] == "" {
+			return UnitCount
+		}
+		return jvmUnits[leaf]
+	}
+
+	name := props["name"]
+	switch {
+	case rateAttributes[attr]:
+		if unit, ok := siblings["RateUnit"].(string); ok {
+			return unit
+		}
+		return UnitEventsPerSecond
+	case isBytesName(name) && (attr == "Count" || attr == "Value" || distributionAttributes[attr]):
+		return UnitBytes
+	case strings.HasSuffix(name, "TotalLatency") && attr == "Count":
+		return UnitMicroseconds
+	case distributionAttributes[attr]:
+		for _, unitAttr := range []string{"DurationUnit", "LatencyUnit"} {
+			if unit, ok := siblings[unitAttr].(string); ok {
+				return unit
+			}
+		}
+		// the dynamic names of the catalog may be byte or count histograms
+		// as well as timers, so their unit isn't known
+		if name == "" {
+			return ""
+		}
+		if strings.Contains(name, "Latency") {
+			return UnitMicroseconds
+		}
+		return UnitCount
+	case attr == "Count":
+		// the dynamic names of the catalog may be sizes in bytes or
+		// latencies as well as counts, so their unit isn't known
+		if name == "" {
+			return ""
+		}
+		return UnitCount
+	case attr == "Value" && (strings.HasSuffix(name, "Ratio") || strings.HasSuffix(name, "HitRate")):
+		return UnitRatio
+	}
+	return ""
+}
+
+// isBytesName returns true if the metric of the name is a size in bytes
+func isBytesName(name string) bool {
+	for _, s := range []string{"Bytes", "Size", "DiskSpace", "Capacity", "MemoryUsed"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return name == "Load"
+}
+
+// metricDescription returns the description of the attribute
+func metricDescription(attr string) string {
+	path := strings.Split(attr, Slash)
+	if desc, ok := attributeDescriptions[attr]; ok {
+		return desc
+	}
+	if strings.HasSuffix(attr, StateSuffix) {
+		return "The state of " + strings.TrimSuffix(attr, StateSuffix) + " as a number"
+	}
+	return attributeDescriptions[path[len(path)-1]]
+}
+
+// describeMetricType returns the metric type with the unit and the description
+// of its attribute. The ObjectName properties are read from the namespace,
+// the ones whose value is a dynamic element are left empty.
+func describeMetricType(mt plugin.MetricType) plugin.MetricType {
+	ns := mt.Namespace()
+	if len(ns) < 6 {
+		return mt
+	}
+
+	domain := decodeElement(ns[4].Value)
+	props := map[string]string{}
+	i := 5
+	for ; i+1 < len(ns)-1; i += 2 {
+		if ns[i+1].IsDynamic() {
+			props[decodeElement(ns[i].Value)] = ""
+			continue
+		}
+		break
+	}
+
+	attr := []string{}
+	for _, e := range ns[i:] {
+		attr = append(attr, decodeElement(e.Value))
+	}
+	name := strings.Join(attr, Slash)
+	mt.Unit_ = metricUnit(domain, props, name, nil)
+	mt.Description_ = metricDescription(name)
+	return mt
+}
+
+// describeMetricTypes returns the metric types with their units and descriptions
+func describeMetricTypes(mts []plugin.MetricType) []plugin.MetricType {
+	described := []plugin.MetricType{}
+	for _, mt := range mts {
+		described = append(described, describeMetricType(mt))
+	}
+	return described
+}
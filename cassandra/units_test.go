@@ -0,0 +1,82 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricUnits(t *testing.T) {
+	Convey("Given the Cassandra metric kinds", t, func() {
+		timer := map[string]string{"type": "Table", "name": "ReadLatency"}
+
+		Convey("timers should be in the duration unit they report", func() {
+			So(metricUnit(MetricDomain, timer, "99thPercentile", nil), ShouldEqual, UnitMicroseconds)
+			So(metricUnit(MetricDomain, timer, "Mean", map[string]interface{}{"DurationUnit": UnitMilliseconds}), ShouldEqual, UnitMilliseconds)
+		})
+
+		Convey("meters should be in events per second", func() {
+			So(metricUnit(MetricDomain, timer, "OneMinuteRate", nil), ShouldEqual, UnitEventsPerSecond)
+		})
+
+		Convey("counters should be counts", func() {
+			So(metricUnit(MetricDomain, timer, "Count", nil), ShouldEqual, UnitCount)
+			So(metricUnit(MetricDomain, map[string]string{"name": "PendingTasks"}, "Count", nil), ShouldEqual, UnitCount)
+		})
+
+		Convey("sizes should be in bytes", func() {
+			So(metricUnit(MetricDomain, map[string]string{"name": "LiveDiskSpaceUsed"}, "Count", nil), ShouldEqual, UnitBytes)
+			So(metricUnit(MetricDomain, map[string]string{"name": "MeanPartitionSize"}, "Value", nil), ShouldEqual, UnitBytes)
+			So(metricUnit(JVMDomain, nil, "HeapMemoryUsage/used", nil), ShouldEqual, UnitBytes)
+		})
+
+		Convey("distributions and counters of a dynamic name should have no unit", func() {
+			So(metricUnit(MetricDomain, map[string]string{}, "99thPercentile", nil), ShouldEqual, "")
+			So(metricUnit(MetricDomain, map[string]string{"name": ""}, "Count", nil), ShouldEqual, "")
+			So(metricUnit(MetricDomain, map[string]string{}, "Max", map[string]interface{}{"DurationUnit": UnitMilliseconds}), ShouldEqual, UnitMilliseconds)
+		})
+
+		Convey("the catalog units should not be told from the first MBean of a dynamic name", func() {
+			types := elementTypes("org.apache.cassandra.metrics:type=Table,keyspace=ks,scope=t,name=LiveDiskSpaceUsed",
+				[]Attribute{{Name: "Count", Type: "long", Value: int64(1)}})
+			So(types, ShouldHaveLength, 1)
+			So(types[0].Unit(), ShouldEqual, "")
+		})
+
+		Convey("the catalog should describe its metric types", func() {
+			mt := describeMetricType(plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "cassandra", "node").
+					AddDynamicElement("nodeName", "").
+					AddStaticElement("org_apache_cassandra_metrics").
+					AddStaticElement("type").AddDynamicElement("type", "").
+					AddStaticElement("name").AddDynamicElement("name", "").
+					AddStaticElement("FiveMinuteRate"),
+			})
+			So(mt.Unit(), ShouldEqual, UnitEventsPerSecond)
+			So(mt.Description(), ShouldEqual, attributeDescriptions["FiveMinuteRate"])
+		})
+	})
+}
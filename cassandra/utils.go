@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/intelsdi-x/snap-plugin-utilities/config"
 	"github.com/intelsdi-x/snap/control/plugin"
@@ -25,86 +30,268 @@ const (
 	Dot        = "."
 	Underscore = "_"
 	Root       = "Root"
+
+	// Redacted replaces the secrets in the log
+	Redacted = "[REDACTED]"
 )
 
 var (
-	cassLog = log.WithField("_module", "cass-collector-client")
+	// logger the logger of the plugin, which redacts the secrets of the
+	// clusters, so the loggers of the other packages aren't affected
+	logger  = newLogger()
+	cassLog = logger.WithField("_module", "cass-collector-client")
+
+	// secrets counts the clusters using each secret
+	secretsMutex sync.RWMutex
+	secrets      = map[string]int{}
+
+	// credentialFields are the log fields whose values are always redacted
+	credentialFields = map[string]bool{
+		"password":      true,
+		"token":         true,
+		"authorization": true,
+		"secret":        true,
+	}
 )
 
-func initClient(cfg interface{}) (*CassClient, error) {
-	items, err := config.GetConfigItems(cfg, CassURL, Port)
+// newLogger returns the logger of the plugin
+func newLogger() *log.Logger {
+	l := log.New()
+	l.Hooks.Add(redactHook{})
+	return l
+}
+
+// addSecrets registers the credentials of a cluster which must never be logged
+func addSecrets(s []string) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, secret := range s {
+		if secret != "" {
+			secrets[secret]++
+		}
+	}
+}
+
+// removeSecrets unregisters the credentials of a cluster, they're
+// still redacted as long as another cluster uses them
+func removeSecrets(s []string) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, secret := range s {
+		if secrets[secret] <= 1 {
+			delete(secrets, secret)
+			continue
+		}
+		secrets[secret]--
+	}
+}
+
+// redact returns the string with every registered secret replaced
+// where it's a whole token, so a short secret doesn't mangle the words
+// it happens to be part of
+func redact(s string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	for secret := range secrets {
+		s = replaceToken(s, secret, Redacted)
+	}
+	return s
+}
+
+// replaceToken replaces the occurrences of old in s which are neither
+// preceded nor followed by a token character
+func replaceToken(s, old, new string) string {
+	result := ""
+	for {
+		i := strings.Index(s, old)
+		if i < 0 {
+			return result + s
+		}
+		end := i + len(old)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (i > 0 && isTokenRune(before)) || (end < len(s) && isTokenRune(after)) {
+			result += s[:i+1]
+			s = s[i+1:]
+			continue
+		}
+		result += s[:i] + new
+		s = s[end:]
+	}
+}
+
+// isTokenRune returns true if the rune may be part of a credential,
+// such as a password or a base64 encoded token. The = of base64 padding
+// is left out, as it separates the keys from the secrets of key=secret.
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_+/", r)
+}
+
+// redactHook removes the credentials from the message and
+// the fields of every log entry
+type redactHook struct{}
+
+// Levels returns all log levels
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire replaces the secrets in the entry. The fields are copied, as
+// they may be shared with the entry the log entry was derived from.
+func (redactHook) Fire(entry *log.Entry) error {
+	entry.Message = redact(entry.Message)
+	data := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if credentialFields[strings.ToLower(key)] {
+			data[key] = Redacted
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			data[key] = redact(v)
+		case error:
+			data[key] = redact(v.Error())
+		case fmt.Stringer:
+			data[key] = redact(v.String())
+		default:
+			data[key] = value
+		}
+	}
+	entry.Data = data
+	return nil
+}
+
+// matchNodeName returns true if the requested node name element
+// matches the host. It may be a wildcard or names separated by pipes.
+func matchNodeName(name, host string) bool {
+	if name == Wildcard {
+		return true
+	}
+	for _, token := range strings.Split(name, Pipe) {
+		if token == host {
+			return true
+		}
+	}
+	return false
+}
+
+// getOptionalString returns the string config item of the key
+// or the default value if it's not configured.
+func getOptionalString(cfg interface{}, key, def string) string {
+	item, err := config.GetConfigItem(cfg, key)
 	if err != nil {
-		return nil, err
+		return def
+	}
+	s, ok := item.(string)
+	if !ok || s == "" {
+		return def
+	}
+	return s
+}
+
+// getOptionalInt returns the int config item of the key
+// or the default value if it's not configured.
+func getOptionalInt(cfg interface{}, key string, def int) int {
+	item, err := config.GetConfigItem(cfg, key)
+	if err != nil {
+		return def
+	}
+	i, ok := item.(int)
+	if !ok {
+		return def
 	}
+	return i
+}
 
-	url := items[CassURL].(string)
-	if url == "" {
-		return nil, errors.New(InvalidURL)
+// getDuration returns the duration config item of the key, a number of
+// seconds, or the default value if it's not configured. It's an error if
+// the item isn't an integer or is negative.
+func getDuration(cfg interface{}, key string, def time.Duration) (time.Duration, error) {
+	item, err := config.GetConfigItem(cfg, key)
+	if err != nil {
+		return def, nil
+	}
+	seconds, ok := item.(int)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s: %v is not a number of seconds", InvalidDuration, key, item)
+	}
+	if seconds < 0 {
+		return 0, fmt.Errorf("%s: %s: %d is negative", InvalidDuration, key, seconds)
 	}
+	return time.Duration(seconds) * time.Second, nil
+}
 
-	port := items[Port].(int)
-	hostname, err := net.LookupAddr(url)
+// getOptionalBool returns the bool config item of the key
+// or the default value if it's not configured.
+func getOptionalBool(cfg interface{}, key string, def bool) bool {
+	item, err := config.GetConfigItem(cfg, key)
 	if err != nil {
-		hostname = []string{url}
+		return def
+	}
+	b, ok := item.(bool)
+	if !ok {
+		return def
 	}
+	return b
+}
 
-	server := fmt.Sprintf("%s:%d", url, port)
-	return NewCassClient(server, hostname[0]), nil
+// readMetricType reads the metric types saved in the catalog directory
+func readMetricType(dir string) ([]plugin.MetricType, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, MetricTypeFile))
+	if err != nil {
+		return nil, err
+	}
+	return parseMetricTypes(data)
 }
 
-func readMetricType() ([]plugin.MetricType, error) {
-	data, err := Asset("data/CassandraMetricType.json")
+// readMetricTypeAsset reads the embedded metric types of the asset
+func readMetricTypeAsset(asset string) ([]plugin.MetricType, error) {
+	data, err := Asset(asset)
 	if err != nil {
 		return nil, err
 	}
+	return parseMetricTypes(data)
+}
 
+// parseMetricTypes returns the metric types of the file
+func parseMetricTypes(data []byte) ([]plugin.MetricType, error) {
 	if len(data) == 0 {
 		return nil, errors.New(ReadDocErr)
 	}
 	var metricTypes []plugin.MetricType
-	err = json.Unmarshal(data, &metricTypes)
+	err := json.Unmarshal(data, &metricTypes)
 	if err != nil {
 		return nil, err
 	}
 	return metricTypes, nil
 }
 
-func writeMetricTypes(types []plugin.MetricType) error {
+// writeMetricTypes saves the metric types in the catalog directory, if any
+func writeMetricTypes(dir string, types []plugin.MetricType) error {
+	if dir == "" {
+		return nil
+	}
 	tys, err := json.Marshal(types)
 	if err != nil {
 		return err
 	}
-
-	jsonFile, err := os.Create("data/CassandraMetricType.json")
-	if err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	defer jsonFile.Close()
-
-	jsonFile.Write(tys)
-	jsonFile.Close()
-	return nil
+	return writeFileAtomic(filepath.Join(dir, MetricTypeFile), tys)
 }
 
-func writeMetricAPIs(node *node) error {
+// writeMetricAPIs saves the tree in the file
+func writeMetricAPIs(path string, node *node) error {
 	tree, err := json.MarshalIndent(node, "", " ")
 	if err != nil {
 		return err
 	}
-
-	jsonFile, err := os.Create("data/CassandraMetricAPI.json")
-	if err != nil {
-		return err
-	}
-	defer jsonFile.Close()
-
-	jsonFile.Write(tree)
-	jsonFile.Close()
-	return nil
+	return writeFileAtomic(path, tree)
 }
 
-func readMetricAPI() (*node, error) {
-	content, err := Asset("data/CassandraMetricAPI.json")
+func readMetricAPI(asset string) (*node, error) {
+	content, err := Asset(asset)
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +307,6 @@ func readMetricAPI() (*node, error) {
 	return jtree, nil
 }
 
-func replaceDotToUnderscore(s string) string {
-	if strings.Contains(s, Dot) {
-		return strings.Replace(s, Dot, Underscore, -1)
-	}
-	return s
-}
-
 func replaceUnderscoreToDot(s string) string {
 	if strings.Contains(s, Underscore) {
 		return strings.Replace(s, Underscore, Dot, -1)
@@ -134,18 +314,24 @@ func replaceUnderscoreToDot(s string) string {
 	return s
 }
 
-// makeLitteralNamespace returns a string array without any modification
-// to input string
+// makeLitteralNamespace returns the domain of the ObjectName followed by its
+// keys and their unquoted values in the declared order, and the name if any.
+// An ObjectName which can't be parsed is a single element.
 func makeLitteralNamespace(url, name string) []string {
 	ns := []string{}
 
-	sp := strings.Split(url, ":")
-	ns = append(ns, sp[0])
-
-	sp1 := strings.Split(sp[1], ",")
-	for _, s := range sp1 {
-		v := strings.Split(s, "=")
-		ns = append(ns, v...)
+	on, err := ParseObjectName(url)
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "makeLitteralNamespace",
+			"error":  err,
+		}).Warn(InvalidObjectNameErr)
+		ns = append(ns, url)
+	} else {
+		ns = append(ns, on.Domain)
+		for _, prop := range on.Properties {
+			ns = append(ns, prop.Key, prop.Value)
+		}
 	}
 
 	if len(name) > 0 {
@@ -158,18 +344,22 @@ func makeLitteralNamespace(url, name string) []string {
 func makeDynamicNamespace(host, url, name string) core.Namespace {
 	ns := core.NewNamespace("intel", "cassandra", "node").AddDynamicElement("nodeName", "The name of a Cassandra node")
 
-	sp := strings.Split(replaceDotToUnderscore(url), ":")
-	ns = ns.AddStaticElement(sp[0])
-
-	sp1 := strings.Split(sp[1], ",")
-	for _, s := range sp1 {
-		v := strings.Split(s, "=")
-		ns = ns.AddStaticElement(v[0])
-		ns = ns.AddDynamicElement(v[0]+" value", "The value of "+v[0])
+	on, err := ParseObjectName(url)
+	if err != nil {
+		ns = ns.AddStaticElement(encodeElement(url))
+	} else {
+		ns = ns.AddStaticElement(encodeElement(on.Domain))
+		for _, prop := range on.Properties {
+			ns = ns.AddStaticElement(encodeElement(prop.Key))
+			ns = ns.AddDynamicElement(prop.Key+" value", "The value of "+prop.Key)
+		}
 	}
 
+	// the items of composite attributes are elements of their own
 	if name != "" {
-		ns = ns.AddStaticElement(name)
+		for _, element := range strings.Split(name, Slash) {
+			ns = ns.AddStaticElement(encodeElement(element))
+		}
 	}
 	return ns
 }
@@ -0,0 +1,156 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// const defines constant varaibles
+const (
+	// ReleaseVersionAttr the attribute of StorageService holding the node's version, e.g. 3.0.15
+	ReleaseVersionAttr = "ReleaseVersion"
+
+	NoReleaseVersionErr = "The release version of the node could not be read"
+	NoCatalogErr        = "No metric catalog for the version, the MBeans of the node are discovered"
+
+	// catalogAssets the directory of the embedded catalogs, which holds the
+	// catalogs of the major versions in directories named after them
+	catalogAssets = "data/"
+)
+
+// catalog defines the assets of the searchable tree and of the metric types
+// generated from the nodes of a major version
+type catalog struct {
+	tree  string
+	types string
+}
+
+// defaultCatalog the catalog read when the version of the node is unknown.
+// It was generated from a 3.x node older than 3.6: it holds the view metrics
+// added in 3.0 but not the ChunkCache added in 3.6.
+var defaultCatalog = catalog{
+	tree:  catalogAssets + MetricAPIFile,
+	types: catalogAssets + MetricTypeFile,
+}
+
+// catalogs are the catalogs by the major versions of Cassandra whose MBeans
+// they hold. The MBeans differ across the versions, e.g. the tables are
+// reported under both ColumnFamily and Table in 3.x. The nodes of the other
+// versions are discovered by listing their MBeans.
+var catalogs = embeddedCatalogs(AssetNames())
+
+// embeddedCatalogs returns the default catalog for the releases it was
+// generated from, along with every catalog embedded in a directory of its
+// major version, e.g. data/4.0/, as written by the dump command of the tool
+func embeddedCatalogs(assets []string) map[string]catalog {
+	cs := map[string]catalog{}
+	for _, version := range []string{"3.0", "3.1", "3.2", "3.3", "3.4", "3.5"} {
+		cs[version] = defaultCatalog
+	}
+
+	embedded := map[string]bool{}
+	for _, asset := range assets {
+		embedded[asset] = true
+	}
+	for _, asset := range assets {
+		dir, file := path.Split(asset)
+		version := strings.TrimSuffix(strings.TrimPrefix(dir, catalogAssets), "/")
+		if file != MetricAPIFile || !strings.HasPrefix(dir, catalogAssets) || version == "" || strings.Contains(version, "/") {
+			continue
+		}
+		if types := dir + MetricTypeFile; embedded[types] {
+			cs[version] = catalog{tree: asset, types: types}
+		}
+	}
+	return cs
+}
+
+// majorVersion returns the major and minor version of the release, e.g. 4.0
+// of 4.0.11 or 5.0 of 5.0-beta1, or the release as it is if it has no minor version
+func majorVersion(release string) string {
+	if i := strings.IndexAny(release, "-+"); i >= 0 {
+		release = release[:i]
+	}
+	parts := strings.SplitN(release, Dot, 3)
+	if len(parts) < 2 {
+		return release
+	}
+	return parts[0] + Dot + parts[1]
+}
+
+// loadTree sets the searchable tree of the node. The catalog saved in the
+// catalog directory for the node's version and schema is read in preference
+// to the embedded catalog of its version, and a node of another version has
+// its MBeans discovered. If the version can't be read the default catalog is
+// read, and it's merged with the node's MBeans when the tree is refreshed.
+func (cc *CassClient) loadTree() error {
+	version, err := readNodeVersion(cc.transport)
+	if err != nil {
+		cassLog.WithFields(log.Fields{
+			"_block": "loadTree",
+			"node":   cc.host,
+			"error":  err,
+		}).Warn(NoReleaseVersionErr)
+		return cc.readTree(defaultCatalog.tree)
+	}
+	cc.version = version
+
+	if cc.catalogDir != "" {
+		nod, err := loadCatalog(cc.catalogDir, version)
+		if err == nil {
+			cc.Root = nod
+			cc.refreshed = time.Now()
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			cassLog.WithFields(log.Fields{
+				"_block": "loadTree",
+				"node":   cc.host,
+				"error":  err,
+			}).Info(StaleCatalogErr)
+		}
+	}
+
+	if c, ok := catalogs[majorVersion(version.Release)]; ok {
+		return cc.readTree(c.tree)
+	}
+	cassLog.WithFields(log.Fields{
+		"_block":  "loadTree",
+		"node":    cc.host,
+		"version": version.Release,
+	}).Info(NoCatalogErr)
+	return cc.buidMetricAPI()
+}
+
+// readTree sets the tree read from the asset, or the tree built from the
+// node's MBeans if the asset can't be read
+func (cc *CassClient) readTree(asset string) error {
+	nod, err := readMetricAPI(asset)
+	if err != nil {
+		return cc.buidMetricAPI()
+	}
+	cc.Root = nod
+	return nil
+}
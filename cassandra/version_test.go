@@ -0,0 +1,86 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVersionCatalogs(t *testing.T) {
+	Convey("The major version should be told from the release", t, func() {
+		So(majorVersion("3.11.4"), ShouldEqual, "3.11")
+		So(majorVersion("4.1.3"), ShouldEqual, "4.1")
+		So(majorVersion("5.0-beta1"), ShouldEqual, "5.0")
+		So(majorVersion("4"), ShouldEqual, "4")
+	})
+
+	Convey("The catalogs embedded in the directories of major versions should be registered", t, func() {
+		cs := embeddedCatalogs([]string{
+			"data/CassandraMetricAPI.json",
+			"data/CassandraMetricType.json",
+			"data/4.0/CassandraMetricAPI.json",
+			"data/4.0/CassandraMetricType.json",
+			"data/4.1/CassandraMetricAPI.json",
+		})
+		So(cs["3.0"], ShouldResemble, defaultCatalog)
+		So(cs["4.0"], ShouldResemble, catalog{tree: "data/4.0/CassandraMetricAPI.json", types: "data/4.0/CassandraMetricType.json"})
+		So(cs, ShouldNotContainKey, "4.1")
+		So(cs, ShouldNotContainKey, "3.11")
+	})
+
+	Convey("Given nodes of different versions", t, func() {
+		transport := newFakeTransport()
+		types := func(cc *CassClient) map[string]*node {
+			return cc.Root.Children[MetricDomain].Children["type"].Children
+		}
+
+		Convey("a node with a catalog should read the catalog of its version", func() {
+			transport.mbeans[StorageServiceMBean] = []Attribute{{Name: ReleaseVersionAttr, Type: "java.lang.String", Value: "3.0.15"}}
+			cc := NewCassClient("node1", transport)
+			So(cc.loadTree(), ShouldBeNil)
+			So(cc.version.Release, ShouldEqual, "3.0.15")
+			So(types(cc), ShouldContainKey, "ColumnFamily")
+			So(cc.refreshed.IsZero(), ShouldBeTrue)
+		})
+
+		Convey("a node of a version without a catalog should have its MBeans discovered", func() {
+			for _, release := range []string{"3.11.4", "4.0.11"} {
+				transport.mbeans[StorageServiceMBean] = []Attribute{{Name: ReleaseVersionAttr, Type: "java.lang.String", Value: release}}
+				cc := NewCassClient("node1", transport)
+				So(cc.loadTree(), ShouldBeNil)
+				So(cc.version.Release, ShouldEqual, release)
+				So(types(cc), ShouldContainKey, "Cache")
+				So(types(cc), ShouldNotContainKey, "ColumnFamily")
+				So(cc.refreshed.IsZero(), ShouldBeFalse)
+			}
+		})
+
+		Convey("a node whose version can't be read should read the default catalog", func() {
+			cc := NewCassClient("node1", transport)
+			So(cc.loadTree(), ShouldBeNil)
+			So(cc.version.Release, ShouldBeEmpty)
+			So(types(cc), ShouldContainKey, "ColumnFamily")
+		})
+	})
+}
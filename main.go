@@ -25,8 +25,14 @@ import (
 	"github.com/intelsdi-x/snap/control/plugin"
 )
 
-// plugin bootstrap
+// plugin bootstrap. The catalog tool is run instead if the
+// first argument is one of its commands.
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 	plugin.Start(
 		cassandra.Meta(),
 		cassandra.NewCassandraCollector(),
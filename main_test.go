@@ -34,3 +34,16 @@ func TestMain(t *testing.T) {
 		So(func() { main() }, ShouldNotPanic)
 	})
 }
+
+func TestCatalogTool(t *testing.T) {
+	Convey("the catalog tool should require the node", t, func() {
+		for _, command := range commands {
+			So(command([]string{}), ShouldEqual, 2)
+			So(command([]string{"-url", "127.0.0.1", "extra"}), ShouldEqual, 2)
+		}
+	})
+
+	Convey("the catalog tool should fail if the node can't be reached", t, func() {
+		So(diff([]string{"-url", "127.0.0.1", "-port", "1", "-timeout", "1s"}), ShouldEqual, 2)
+	})
+}
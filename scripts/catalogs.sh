@@ -0,0 +1,54 @@
+#!/usr/bin/env bash
+
+# http://www.apache.org/licenses/LICENSE-2.0.txt
+#
+#
+# Copyright 2016 Intel Corporation
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Dumps the catalogs of the nodes given as arguments, one node per major
+# version of Cassandra, in cassandra/data/<version>/ and embeds them by
+# regenerating cassandra/metric.go, e.g.
+#   TRANSPORT=jolokia ./scripts/catalogs.sh cassandra-311 cassandra-40 cassandra-41 cassandra-50
+
+set -e
+set -u
+set -o pipefail
+
+__dir="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+__proj_dir="$(dirname "$__dir")"
+
+# shellcheck source=scripts/common.sh
+. "${__dir}/common.sh"
+
+TRANSPORT="${TRANSPORT:-mx4j}"
+PORT="${PORT:-}"
+
+if [[ $# -eq 0 ]]; then
+  _error "usage: $0 <node> [<node>...]"
+fi
+
+port_flag=()
+if [[ -n "${PORT}" ]]; then
+  port_flag=(-port "${PORT}")
+fi
+
+cd "${__proj_dir}"
+for node in "$@"; do
+  _info "dumping the catalog of ${node}"
+  go run . dump -url "${node}" -transport "${TRANSPORT}" "${port_flag[@]+"${port_flag[@]}"}" -dir cassandra/data
+done
+
+_info "embedding the catalogs"
+(cd cassandra && go-bindata -pkg cassandra -o metric.go data/...)
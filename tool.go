@@ -0,0 +1,167 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cassandra/cassandra"
+)
+
+// commands are the commands of the catalog tool by their names, each
+// returning the exit status of the tool, 2 if it failed as with diff(1)
+var commands = map[string]func(args []string) int{
+	"dump": dump,
+	"diff": diff,
+	"docs": docs,
+}
+
+// nodeFlags defines the flags of the node the tool connects to
+type nodeFlags struct {
+	url, transport, scheme          string
+	port, timeout                   int
+	username, passwordEnv, tokenEnv string
+}
+
+// newFlagSet returns the flags of the command along with the flags of the node
+func newFlagSet(name, usage string) (*flag.FlagSet, *nodeFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s -url <node> [flags]\n%s\n", os.Args[0], name, usage)
+		fs.PrintDefaults()
+	}
+	nf := &nodeFlags{}
+	fs.StringVar(&nf.url, "url", "", "the address of the node")
+	fs.IntVar(&nf.port, "port", 0, "the port of the MX4J or Jolokia endpoint, 8082 or 8778 if not set")
+	fs.StringVar(&nf.transport, "transport", cassandra.MX4JTransport, "mx4j or jolokia")
+	fs.StringVar(&nf.scheme, "scheme", cassandra.HTTPScheme, "http or https")
+	fs.IntVar(&nf.timeout, "timeout", int(cassandra.DefaultTimeout/time.Second), "the timeout of each request in seconds")
+	fs.StringVar(&nf.username, "username", "", "the user of basic authentication")
+	fs.StringVar(&nf.passwordEnv, "password-env", "", "the environment variable the password is read from")
+	fs.StringVar(&nf.tokenEnv, "token-env", "", "the environment variable the bearer token is read from")
+	return fs, nf
+}
+
+// parse parses the arguments and returns the config of the node, or false
+// if the arguments are invalid
+func parse(fs *flag.FlagSet, nf *nodeFlags, args []string) (map[string]interface{}, bool) {
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	if nf.url == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return nil, false
+	}
+	items := map[string]interface{}{
+		cassandra.CassURL:        nf.url,
+		cassandra.CassTransport:  nf.transport,
+		cassandra.Scheme:         nf.scheme,
+		cassandra.RequestTimeout: nf.timeout,
+	}
+	if nf.port != 0 {
+		items[cassandra.Port] = nf.port
+	}
+	if nf.username != "" {
+		items[cassandra.Username] = nf.username
+	}
+	if nf.passwordEnv != "" {
+		items[cassandra.PasswordEnv] = nf.passwordEnv
+	}
+	if nf.tokenEnv != "" {
+		items[cassandra.TokenEnv] = nf.tokenEnv
+	}
+	return items, true
+}
+
+// fail prints the error and returns the exit status of a failure
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return 2
+}
+
+// dump writes the catalog of the node in the CassandraMetricAPI.json format
+func dump(args []string) int {
+	fs, nf := newFlagSet("dump", "Writes the MBean catalog of the node as CassandraMetricAPI.json to the output file or stdout,\nor the catalog and the metric types of its major version in the catalog directory.")
+	out := fs.String("o", "", "the output file, stdout if not set")
+	dir := fs.String("dir", "", "the catalog directory, e.g. cassandra/data, the catalog is written in its directory of the node's major version")
+	items, ok := parse(fs, nf, args)
+	if !ok {
+		return 2
+	}
+
+	if *dir != "" {
+		path, err := cassandra.DumpCatalogDir(cassandra.NewConfig(items), *dir)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(path)
+		return 0
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := cassandra.DumpCatalog(cassandra.NewConfig(items), w); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// diff compares the catalog of the node with the embedded one. Like diff(1)
+// it exits with 1 if they differ.
+func diff(args []string) int {
+	fs, nf := newFlagSet("diff", "Lists the MBeans the node adds (+) to or lacks (-) from the embedded catalog of its version.")
+	items, ok := parse(fs, nf, args)
+	if !ok {
+		return 2
+	}
+
+	n, err := cassandra.DiffCatalog(cassandra.NewConfig(items), os.Stdout)
+	if err != nil {
+		return fail(err)
+	}
+	if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+// docs regenerates METRICS.md and DYNAMIC_METRICS.md
+func docs(args []string) int {
+	fs, nf := newFlagSet("docs", "Regenerates METRICS.md and DYNAMIC_METRICS.md from the metrics of the node.")
+	dir := fs.String("dir", ".", "the directory of the documents")
+	items, ok := parse(fs, nf, args)
+	if !ok {
+		return 2
+	}
+
+	if err := cassandra.WriteMetricDocs(cassandra.NewConfig(items), *dir); err != nil {
+		return fail(err)
+	}
+	return 0
+}